@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+	"github.com/devinodaniel/cron-go/cmd/monitor"
+)
+
+// CgroupUsage is the accounting of the run's own cgroup, it covers every
+// process the command started, waited for or not
+type CgroupUsage struct {
+	Path             string  `json:"path"`
+	CPUUserSeconds   float64 `json:"cpuUserSeconds"`
+	CPUSystemSeconds float64 `json:"cpuSystemSeconds"`
+	MemoryPeakBytes  int64   `json:"memoryPeakBytes,omitempty"` // kernel 5.19+ with the memory controller
+	OOMKills         int64   `json:"oomKills"`
+}
+
+// cgroupEnabled returns whether the run should get its own cgroup
+func cgroupEnabled() bool {
+	return config.CRON_CGROUP || config.CRON_MEMORY_MAX != "" || config.CRON_CPU_MAX != "" ||
+		config.CRON_PIDS_MAX > 0 || len(config.CRON_IO_MAX) > 0
+}
+
+// cgroupLimits returns the cgroup interface files to write and what to write
+// to them, in the order they're applied, the controller is the file's prefix
+func cgroupLimits() ([][2]string, error) {
+	var limits [][2]string
+
+	if config.CRON_MEMORY_MAX != "" {
+		limits = append(limits, [2]string{"memory.max", config.CRON_MEMORY_MAX})
+	}
+
+	if config.CRON_CPU_MAX != "" {
+		cpuMax, err := parseCPUMax(config.CRON_CPU_MAX)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, [2]string{"cpu.max", cpuMax})
+	}
+
+	if config.CRON_PIDS_MAX > 0 {
+		limits = append(limits, [2]string{"pids.max", strconv.Itoa(config.CRON_PIDS_MAX)})
+	}
+
+	// io.max takes a single device per write
+	for _, device := range config.CRON_IO_MAX {
+		limits = append(limits, [2]string{"io.max", device})
+	}
+
+	return limits, nil
+}
+
+// parseCPUMax accepts a number of cores, ie: 1.5, or the raw cpu.max format
+// <quota> <period>, ie: 150000 100000
+func parseCPUMax(value string) (string, error) {
+	if strings.Contains(strings.TrimSpace(value), " ") {
+		return value, nil
+	}
+
+	cores, err := strconv.ParseFloat(value, 64)
+	if err != nil || cores <= 0 {
+		return "", fmt.Errorf("invalid CRON_CPU_MAX: %s", value)
+	}
+
+	const period = 100000
+	return fmt.Sprintf("%d %d", int64(cores*period), period), nil
+}
+
+// applyCgroup turns a failure caused by the kernel's OOM killer into its own status
+func (c *Cron) applyCgroup() {
+	if c.Cgroup == nil || c.Cgroup.OOMKills == 0 || c.StatusCode != CRON_STATUS_FAIL {
+		return
+	}
+
+	c.StatusCode = CRON_STATUS_OOM_KILLED
+}
+
+// setCgroupMetrics sets the accounting metrics of the run's cgroup
+func (c *Cron) setCgroupMetrics() {
+	if c.Cgroup == nil {
+		return
+	}
+
+	ns := c.Monitor.Namespace
+	monitor.CronCgroupCPUSeconds.WithLabelValues(ns, "user").Set(c.Cgroup.CPUUserSeconds)
+	monitor.CronCgroupCPUSeconds.WithLabelValues(ns, "system").Set(c.Cgroup.CPUSystemSeconds)
+	monitor.CronOOMKills.WithLabelValues(ns).Set(float64(c.Cgroup.OOMKills))
+	if c.Cgroup.MemoryPeakBytes > 0 {
+		monitor.CronCgroupMemoryPeakBytes.WithLabelValues(ns).Set(float64(c.Cgroup.MemoryPeakBytes))
+	}
+}
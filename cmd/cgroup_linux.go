@@ -0,0 +1,180 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+	"github.com/prometheus/procfs"
+)
+
+// cgroup is the transient cgroup v2 a single run is placed in
+type cgroup struct {
+	path string
+	dir  *os.File // the command is cloned straight into it
+}
+
+// newCgroup creates a cgroup for the run under CRON_CGROUP_PARENT and applies the limits
+func newCgroup(name string) (*cgroup, error) {
+	limits, err := cgroupLimits()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := cgroupMount()
+	if err != nil {
+		return nil, err
+	}
+
+	parent := filepath.Join(root, config.CRON_CGROUP_PARENT)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, err
+	}
+
+	// the limits only exist once their controller is enabled all the way down
+	var controllers []string
+	for _, limit := range limits {
+		controller, _, _ := strings.Cut(limit[0], ".")
+		controllers = append(controllers, controller)
+	}
+	if err := enableControllers(root, parent, controllers); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(parent, name)
+	if err := os.Mkdir(path, 0755); err != nil {
+		return nil, err
+	}
+
+	cg := &cgroup{path: path}
+	for _, limit := range limits {
+		if err := os.WriteFile(filepath.Join(path, limit[0]), []byte(limit[1]), 0644); err != nil {
+			cg.remove()
+			return nil, fmt.Errorf("error setting %s to %s: %v", limit[0], limit[1], err)
+		}
+	}
+
+	cg.dir, err = os.Open(path)
+	if err != nil {
+		cg.remove()
+		return nil, err
+	}
+
+	return cg, nil
+}
+
+// cgroupMount returns where the cgroup v2 hierarchy is mounted, the unified
+// hierarchy of a hybrid setup works too as long as it has the controllers
+func cgroupMount() (string, error) {
+	mounts, err := procfs.GetMounts()
+	if err != nil {
+		return "", err
+	}
+
+	for _, mount := range mounts {
+		if mount.FSType == "cgroup2" {
+			return mount.MountPoint, nil
+		}
+	}
+
+	return "", errors.New("cgroup v2 is not mounted")
+}
+
+// enableControllers enables the controllers for the children of every cgroup
+// from the root down to the parent
+func enableControllers(root, parent string, controllers []string) error {
+	if len(controllers) == 0 {
+		return nil
+	}
+
+	var enable []string
+	for _, controller := range controllers {
+		enable = append(enable, "+"+controller)
+	}
+
+	rel, err := filepath.Rel(root, parent)
+	if err != nil {
+		return err
+	}
+
+	dir := root
+	for _, part := range append([]string{""}, strings.Split(rel, string(filepath.Separator))...) {
+		dir = filepath.Join(dir, part)
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte(strings.Join(enable, " ")), 0644); err != nil {
+			return fmt.Errorf("error enabling the %s controllers in %s: %v", strings.Join(controllers, ", "), dir, err)
+		}
+	}
+
+	return nil
+}
+
+// attach makes the command start inside the cgroup
+func (cg *cgroup) attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cg.dir.Fd())
+}
+
+// usage reads the accounting of the cgroup, files of controllers that aren't
+// enabled are missing and left at zero
+func (cg *cgroup) usage() *CgroupUsage {
+	cpu := readKeyValues(filepath.Join(cg.path, "cpu.stat"))
+	events := readKeyValues(filepath.Join(cg.path, "memory.events"))
+
+	usage := &CgroupUsage{
+		Path:             cg.path,
+		CPUUserSeconds:   float64(cpu["user_usec"]) / 1e6,
+		CPUSystemSeconds: float64(cpu["system_usec"]) / 1e6,
+		OOMKills:         events["oom_kill"],
+	}
+
+	if data, err := os.ReadFile(filepath.Join(cg.path, "memory.peak")); err == nil {
+		usage.MemoryPeakBytes, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	}
+
+	return usage
+}
+
+// remove deletes the cgroup, it's left behind if processes of the command
+// are still running in it
+func (cg *cgroup) remove() error {
+	if cg.dir != nil {
+		cg.dir.Close()
+	}
+	return os.Remove(cg.path)
+}
+
+// readKeyValues reads a flat keyed cgroup file like cpu.stat
+func readKeyValues(path string) map[string]int64 {
+	values := make(map[string]int64)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			values[key] = n
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// cgroup is only supported on linux
+type cgroup struct{}
+
+func newCgroup(name string) (*cgroup, error) {
+	return nil, errors.New("cgroups are only supported on linux")
+}
+
+func (cg *cgroup) attach(cmd *exec.Cmd) {}
+
+func (cg *cgroup) usage() *CgroupUsage { return nil }
+
+func (cg *cgroup) remove() error { return nil }
@@ -0,0 +1,85 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestCgroupAccounting(t *testing.T) {
+	setConfig(t, &config.CRON_CGROUP, true)
+	setConfig(t, &config.CRON_CGROUP_PARENT, fmt.Sprintf("cron-runner-test-%d", os.Getpid()))
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cg, err := newCgroup("probe")
+	if err != nil {
+		t.Skipf("cgroup v2 not usable here: %v", err)
+	}
+	cg.remove()
+	t.Cleanup(func() { os.Remove(filepath.Dir(cg.path)) })
+
+	// the background process isn't waited for, rusage misses it but the cgroup doesn't
+	cron, _ := New([]string{"sh", "-c", "(i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done) & wait"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Fatalf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+	if cron.Cgroup == nil {
+		t.Fatal("Expected the cgroup accounting to be set")
+	}
+	if cron.Cgroup.CPUUserSeconds+cron.Cgroup.CPUSystemSeconds <= 0 {
+		t.Errorf("Expected some cpu time, got %+v", cron.Cgroup)
+	}
+	if _, err := os.Stat(cron.Cgroup.Path); !os.IsNotExist(err) {
+		t.Errorf("Expected the cgroup %s to be removed, got %v", cron.Cgroup.Path, err)
+	}
+}
+
+func TestCgroupOOMKilled(t *testing.T) {
+	cron := &Cron{StatusCode: CRON_STATUS_FAIL, Cgroup: &CgroupUsage{OOMKills: 1}}
+	cron.applyCgroup()
+	if cron.StatusCode != CRON_STATUS_OOM_KILLED {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_OOM_KILLED, cron.StatusCode)
+	}
+
+	// the command survived losing one of its processes
+	cron = &Cron{StatusCode: CRON_STATUS_SUCCESS, Cgroup: &CgroupUsage{OOMKills: 1}}
+	cron.applyCgroup()
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+}
+
+func TestCgroupInvalidLimits(t *testing.T) {
+	setConfig(t, &config.CRON_CPU_MAX, "lots")
+
+	if _, err := New([]string{"true"}); err == nil {
+		t.Errorf("Expected an error for CRON_CPU_MAX=%s, got nil", config.CRON_CPU_MAX)
+	}
+}
+
+func TestCgroupCPUMax(t *testing.T) {
+	for value, expected := range map[string]string{
+		"1":            "100000 100000",
+		"1.5":          "150000 100000",
+		"0.25":         "25000 100000",
+		"50000 100000": "50000 100000",
+		"max 100000":   "max 100000",
+	} {
+		if got, err := parseCPUMax(value); err != nil || got != expected {
+			t.Errorf("Expected %q for CRON_CPU_MAX=%s, got %q (%v)", expected, value, got, err)
+		}
+	}
+
+	for _, value := range []string{"", "0", "-1", "lots"} {
+		if _, err := parseCPUMax(value); err == nil {
+			t.Errorf("Expected an error for CRON_CPU_MAX=%s, got nil", value)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+	"github.com/devinodaniel/cron-go/cmd/monitor"
+)
+
+// openChildMetrics creates the per-run file the command can publish its own
+// metrics to, nil if disabled or it couldn't be created. The command gets it
+// as $CRON_METRICS_OUTPUT and as fd 3, whichever is easier to write to.
+func (c *Cron) openChildMetrics() *os.File {
+	if !config.CRON_CHILD_METRICS || !config.CRON_METRICS {
+		return nil
+	}
+
+	file, err := os.CreateTemp("", "cron-runner-metrics-*.prom")
+	if err != nil {
+		c.logf("WARNING: unable to create child metrics file: %v\n", err)
+		return nil
+	}
+
+	if err := c.runAs.chown(file.Name()); err != nil {
+		c.logf("WARNING: unable to hand the child metrics file over to %s: %v\n", c.runAs.name, err)
+	}
+
+	c.childMetricsPath = file.Name()
+
+	return file
+}
+
+// collectChildMetrics parses the metrics the command published so they're
+// written to the same metrics file as the runner's own
+func (c *Cron) collectChildMetrics() {
+	if c.childMetricsPath == "" {
+		return
+	}
+	defer os.Remove(c.childMetricsPath)
+
+	// don't follow a symlink the command may have put in its place
+	file, err := os.OpenFile(c.childMetricsPath, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		c.logf("WARNING: unable to read child metrics: %v\n", err)
+		return
+	}
+	defer file.Close()
+
+	families, errs := monitor.ParseChildMetrics(file, c.Monitor.Namespace)
+	for _, err := range errs {
+		c.logf("WARNING: child metrics: %v\n", err)
+	}
+
+	c.childMetrics = families
+}
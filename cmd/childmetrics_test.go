@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestChildMetrics(t *testing.T) {
+	setConfig(t, &config.CRON_CHILD_METRICS, true)
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE, "child_metrics")
+
+	script := `echo 'files_uploaded{bucket="logs",namespace="fake"} 3' >&3
+printf '# TYPE rows_processed counter\nrows_processed 42\ncron_status_code 0\n' >> "$CRON_METRICS_OUTPUT"`
+	cron, _ := New([]string{"sh", "-c", script})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(config.CRON_METRICS_DIR, "cron_child_metrics_metrics.prom"))
+	for _, metric := range []string{
+		`cron_job_files_uploaded{bucket="logs",namespace="child_metrics"} 3`,
+		"# TYPE cron_job_rows_processed counter",
+		`cron_job_rows_processed{namespace="child_metrics"} 42`,
+		`cron_status_code{namespace="child_metrics"} 0`,
+	} {
+		if !strings.Contains(string(data), metric) {
+			t.Errorf("Expected metrics file to contain %s", metric)
+		}
+	}
+
+	// the command can't overwrite the runner's own metrics
+	if strings.Contains(string(data), "cron_job_cron_status_code") || strings.Count(string(data), `cron_status_code{namespace="child_metrics"}`) != 1 {
+		t.Errorf("Expected the command's cron_status_code to be rejected, got:\n%s", data)
+	}
+
+	if _, err := os.Stat(cron.childMetricsPath); !os.IsNotExist(err) {
+		t.Errorf("Expected child metrics file %s to be removed", cron.childMetricsPath)
+	}
+}
+
+func TestChildMetricsInvalid(t *testing.T) {
+	setConfig(t, &config.CRON_CHILD_METRICS, true)
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+
+	cron, _ := New([]string{"sh", "-c", `echo 'not prometheus {' >&3`})
+	if err := cron.Run(); err != nil {
+		t.Errorf("Expected invalid child metrics to be ignored, got %v", err)
+	}
+
+	if len(cron.childMetrics) != 0 {
+		t.Errorf("Expected no child metrics, got %d", len(cron.childMetrics))
+	}
+}
+
+func TestChildMetricsTimestamp(t *testing.T) {
+	setConfig(t, &config.CRON_CHILD_METRICS, true)
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE, "child_timestamp")
+
+	script := `printf 'rows{table="a"} 1 1700000000000\nrows{table="b"} 2\nlast_sync 3 1700000000000\n' >&3`
+	cron, _ := New([]string{"sh", "-c", script})
+	cron.Run()
+
+	data, _ := os.ReadFile(filepath.Join(config.CRON_METRICS_DIR, "cron_child_timestamp_metrics.prom"))
+
+	// a single timestamp would make node_exporter drop the runner's metrics too
+	if strings.Contains(string(data), "1700000000000") || strings.Contains(string(data), "cron_job_last_sync") {
+		t.Errorf("Expected the samples with a timestamp to be rejected, got:\n%s", data)
+	}
+	for _, metric := range []string{
+		`cron_job_rows{namespace="child_timestamp",table="b"} 2`,
+		`cron_status_code{namespace="child_timestamp"} 0`,
+	} {
+		if !strings.Contains(string(data), metric) {
+			t.Errorf("Expected metrics file to contain %s", metric)
+		}
+	}
+}
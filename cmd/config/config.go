@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
 var (
@@ -11,8 +12,90 @@ var (
 	CRON_NAMESPACE      = EnvStr("CRON_NAMESPACE", "")  // *optional* underlines and lowercase only
 	CRON_DRYRUN         bool
 	CRON_METRICS        bool
+	CRON_CHILD_METRICS  bool
 	CRON_METRICS_PREFIX = EnvStr("CRON_METRICS_PREFIX", "")                                       // *optional*
 	CRON_METRICS_DIR    = EnvStr("CRON_METRICS_DIR", "/var/lib/node_exporter/textfile_collector") // NO TRAILING SLASH :)
+
+	CRON_STATE_DIR           = EnvStr("CRON_STATE_DIR", "")               // *optional* enables the namespace registry ie: /var/lib/cron-runner
+	CRON_NAMESPACE_COLLISION = EnvStr("CRON_NAMESPACE_COLLISION", "warn") // *optional* warn, fail or suffix
+
+	CRON_REDACT_REGEX  = EnvStr("CRON_REDACT_REGEX", "") // *optional* only capture groups are redacted if the regex has any
+	CRON_REDACT_FLAGS  = EnvList("CRON_REDACT_FLAGS", []string{"password", "passwd", "secret", "token", "api-key", "apikey", "access-key", "secret-key", "client-secret"})
+	CRON_REDACT_ENV    = EnvList("CRON_REDACT_ENV", nil) // *optional* names of env vars whose values are redacted
+	CRON_REDACT_OUTPUT bool
+
+	CRON_REPORT = EnvStr("CRON_REPORT", "") // *optional* file, stdout or fd:<n>
+
+	CRON_EXIT_CODES = EnvList("CRON_EXIT_CODES", nil) // *optional* <code>:<name>[:<status>] ie: 3:PARTIAL_DATA:WARNING
+
+	CRON_OUTPUT_MUST_MATCH     = EnvStr("CRON_OUTPUT_MUST_MATCH", "")     // *optional* regex at least one line must match
+	CRON_OUTPUT_MUST_NOT_MATCH = EnvStr("CRON_OUTPUT_MUST_NOT_MATCH", "") // *optional* regex no line may match
+	CRON_STDERR_EMPTY          bool
+	CRON_OUTPUT_PATTERNS       = EnvStr("CRON_OUTPUT_PATTERNS", "") // *optional* <name>=<regex> separated by semicolons
+
+	CRON_IDLE_TIMEOUT = EnvInt("CRON_IDLE_TIMEOUT", 0) // *optional* seconds without any output before the command is killed, 0 disables
+
+	CRON_METRICS_REFRESH = EnvInt("CRON_METRICS_REFRESH", 60) // *optional* seconds between in-flight metrics writes, 0 disables
+	CRON_SAMPLE_INTERVAL = EnvInt("CRON_SAMPLE_INTERVAL", 5)  // *optional* seconds between samples of the command's processes, 0 disables
+
+	CRON_CGROUP        bool
+	CRON_CGROUP_PARENT = EnvStr("CRON_CGROUP_PARENT", "cron-runner") // *optional* relative to the cgroup v2 mount, must not have processes of its own
+	CRON_MEMORY_MAX    = EnvStr("CRON_MEMORY_MAX", "")               // *optional* memory.max ie: 512M
+	CRON_CPU_MAX       = EnvStr("CRON_CPU_MAX", "")                  // *optional* cores ie: 1.5, or cpu.max ie: 150000 100000
+	CRON_PIDS_MAX      = EnvInt("CRON_PIDS_MAX", 0)                  // *optional* pids.max
+	CRON_IO_MAX        = EnvList("CRON_IO_MAX", nil)                 // *optional* io.max lines ie: 8:0 rbps=1048576 wbps=1048576
+
+	CRON_CHDIR         = EnvStr("CRON_CHDIR", "")         // *optional* working directory of the command
+	CRON_UMASK         = EnvStr("CRON_UMASK", "")         // *optional* octal ie: 027, the runner's 022 if empty
+	CRON_NICE          = EnvStr("CRON_NICE", "")          // *optional* -20 to 19
+	CRON_IONICE        = EnvStr("CRON_IONICE", "")        // *optional* <class>[:<level>] ie: best-effort:7 or idle
+	CRON_CPU_AFFINITY  = EnvStr("CRON_CPU_AFFINITY", "")  // *optional* cpu list ie: 0-3,6
+	CRON_RLIMIT_NOFILE = EnvStr("CRON_RLIMIT_NOFILE", "") // *optional* <soft>[:<hard>], either may be unlimited
+	CRON_RLIMIT_NPROC  = EnvStr("CRON_RLIMIT_NPROC", "")  // *optional*
+	CRON_RLIMIT_CORE   = EnvStr("CRON_RLIMIT_CORE", "")   // *optional* bytes
+	CRON_RLIMIT_AS     = EnvStr("CRON_RLIMIT_AS", "")     // *optional* bytes
+
+	CRON_USER   = EnvStr("CRON_USER", "")     // *optional* name or uid to run the command as, the runner must be root
+	CRON_GROUP  = EnvStr("CRON_GROUP", "")    // *optional* name or gid, the user's primary group if empty
+	CRON_GROUPS = EnvList("CRON_GROUPS", nil) // *optional* supplementary groups, the user's own groups if empty
+
+	CRON_ENV_FILE     = EnvList("CRON_ENV_FILE", nil)     // *optional* dotenv files added to the command's env, later files win
+	CRON_ENV_ALLOW    = EnvList("CRON_ENV_ALLOW", nil)    // *optional* globs of the runner's env vars the command inherits, all if empty
+	CRON_ENV_DENY     = EnvList("CRON_ENV_DENY", nil)     // *optional* globs of the runner's env vars the command doesn't inherit, CRON_* always unless allowed
+	CRON_PATH_PREPEND = EnvList("CRON_PATH_PREPEND", nil) // *optional* directories added in front of the command's PATH
+	CRON_PATH_APPEND  = EnvList("CRON_PATH_APPEND", nil)  // *optional* directories added after the command's PATH
+	CRON_ENV_LOGIN    bool
+
+	CRON_SHELL         = EnvStr("CRON_SHELL", "")                       // *optional* shell to run the single command string with, /bin/bash for --shell if empty
+	CRON_SHELL_OPTIONS = EnvStr("CRON_SHELL_OPTIONS", "-e -o pipefail") // *optional* options passed to the shell before -c
+
+	CRON_PTY bool
+
+	CRON_SCRATCH                bool
+	CRON_SCRATCH_DIR            = EnvStr("CRON_SCRATCH_DIR", "")           // *optional* where scratch directories are created, $TMPDIR if empty
+	CRON_SCRATCH_MAX            = EnvStr("CRON_SCRATCH_MAX", "")           // *optional* size ie: 10G, implies CRON_SCRATCH
+	CRON_SCRATCH_RETENTION_DAYS = EnvInt("CRON_SCRATCH_RETENTION_DAYS", 7) // *optional* days kept scratch directories are swept after, 0 keeps them
+
+	CRON_REQUIRE_FILE    = EnvList("CRON_REQUIRE_FILE", nil)     // *optional* paths that must exist
+	CRON_REQUIRE_DISK    = EnvList("CRON_REQUIRE_DISK", nil)     // *optional* <path>:<size> free space ie: /backup:10G
+	CRON_REQUIRE_TCP     = EnvList("CRON_REQUIRE_TCP", nil)      // *optional* <host>:<port> that must accept connections
+	CRON_REQUIRE_CMD     = EnvStr("CRON_REQUIRE_CMD", "")        // *optional* check run with sh -c that must exit 0
+	CRON_REQUIRE_TIMEOUT = EnvInt("CRON_REQUIRE_TIMEOUT", 10)    // *optional* seconds each check may take
+	CRON_REQUIRE_ACTION  = EnvStr("CRON_REQUIRE_ACTION", "skip") // *optional* skip or fail when a check fails
+
+	CRON_HOOK_PRE        = EnvStr("CRON_HOOK_PRE", "")             // *optional* run with sh -c before the command
+	CRON_HOOK_POST       = EnvStr("CRON_HOOK_POST", "")            // *optional* run with sh -c after the command, whatever happened
+	CRON_HOOK_ON_SUCCESS = EnvStr("CRON_HOOK_ON_SUCCESS", "")      // *optional* run with sh -c after the command succeeded
+	CRON_HOOK_ON_FAILURE = EnvStr("CRON_HOOK_ON_FAILURE", "")      // *optional* run with sh -c after the command failed
+	CRON_HOOK_ON_TIMEOUT = EnvStr("CRON_HOOK_ON_TIMEOUT", "")      // *optional* run with sh -c after the command timed out, instead of CRON_HOOK_ON_FAILURE
+	CRON_HOOK_TIMEOUT    = EnvInt("CRON_HOOK_TIMEOUT", 60)         // *optional* seconds each hook may take
+	CRON_HOOK_PRE_POLICY = EnvStr("CRON_HOOK_PRE_POLICY", "abort") // *optional* abort or continue when CRON_HOOK_PRE fails
+
+	CRON_STDIN         = EnvStr("CRON_STDIN", "")         // *optional* null, inherit or a file path, null if empty
+	CRON_STDIN_CONTENT = EnvStr("CRON_STDIN_CONTENT", "") // *optional* fed to the command as is, instead of CRON_STDIN
+
+	CRON_NOTIFY   bool
+	CRON_WATCHDOG = EnvInt("CRON_WATCHDOG", 0) // *optional* seconds without a WATCHDOG=1 before the command is killed, 0 disables
 )
 
 func init() {
@@ -25,6 +108,38 @@ func init() {
 	if err != nil {
 		fmt.Printf("Error retrieving CRON_METRICS: %v\n", err)
 	}
+	CRON_STDERR_EMPTY, err = EnvBool("CRON_STDERR_EMPTY", false) // *optional*
+	if err != nil {
+		fmt.Printf("Error retrieving CRON_STDERR_EMPTY: %v\n", err)
+	}
+	CRON_CHILD_METRICS, err = EnvBool("CRON_CHILD_METRICS", false) // *optional*
+	if err != nil {
+		fmt.Printf("Error retrieving CRON_CHILD_METRICS: %v\n", err)
+	}
+	CRON_REDACT_OUTPUT, err = EnvBool("CRON_REDACT_OUTPUT", false) // *optional*
+	if err != nil {
+		fmt.Printf("Error retrieving CRON_REDACT_OUTPUT: %v\n", err)
+	}
+	CRON_CGROUP, err = EnvBool("CRON_CGROUP", false) // *optional* any CRON_*_MAX limit implies it
+	if err != nil {
+		fmt.Printf("Error retrieving CRON_CGROUP: %v\n", err)
+	}
+	CRON_ENV_LOGIN, err = EnvBool("CRON_ENV_LOGIN", false) // *optional* only CRON_ENV_ALLOW is inherited then
+	if err != nil {
+		fmt.Printf("Error retrieving CRON_ENV_LOGIN: %v\n", err)
+	}
+	CRON_SCRATCH, err = EnvBool("CRON_SCRATCH", false) // *optional*
+	if err != nil {
+		fmt.Printf("Error retrieving CRON_SCRATCH: %v\n", err)
+	}
+	CRON_PTY, err = EnvBool("CRON_PTY", false) // *optional* stdout and stderr are merged then
+	if err != nil {
+		fmt.Printf("Error retrieving CRON_PTY: %v\n", err)
+	}
+	CRON_NOTIFY, err = EnvBool("CRON_NOTIFY", false) // *optional* CRON_WATCHDOG implies it
+	if err != nil {
+		fmt.Printf("Error retrieving CRON_NOTIFY: %v\n", err)
+	}
 }
 
 // EnvStr retrieves the string value of the environment variable named by the key.
@@ -45,6 +160,20 @@ func EnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// EnvList retrieves the comma separated values of the environment variable named by the key.
+func EnvList(key string, defaultValue []string) []string {
+	if value, found := os.LookupEnv(key); found {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		return list
+	}
+	return defaultValue
+}
+
 // EnvBool retrieves the boolean value of the environment variable named by the key.
 func EnvBool(key string, defaultValue bool) (bool, error) {
 	if value, found := os.LookupEnv(key); found {
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// the PATH login(1) gives a user when /etc/login.defs doesn't say otherwise
+const (
+	loginPath     = "/usr/local/bin:/usr/bin:/bin"
+	loginRootPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+// envNamePattern is what a dotenv file may assign to
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// childEnv returns the environment the command is started with, the job's
+// with the files the runner set up for the command
+func (c *Cron) childEnv(as *runAs) []string {
+	env := c.jobEnv(as)
+
+	if c.childMetricsPath != "" {
+		env = setEnv(env, "CRON_METRICS_OUTPUT="+c.childMetricsPath)
+	}
+
+	if c.scratch != "" {
+		env = setEnv(env, "TMPDIR="+c.scratch, "CRON_WORKDIR="+c.scratch)
+	}
+
+	return env
+}
+
+// jobEnv returns the environment of the job, later sources win: the runner's
+// own environment, the user's login vars, CRON_ENV_FILE, CRON_PATH_* and the
+// details of the current run
+func (c *Cron) jobEnv(as *runAs) []string {
+	// another user doesn't get the runner's env, only what a login gives it
+	login := config.CRON_ENV_LOGIN || as != nil
+
+	env := inheritedEnv(login)
+	if login {
+		env = setEnv(env, loginEnv(as)...)
+	}
+
+	env = setEnv(env, c.envFile...)
+	env = augmentPath(env)
+
+	env = setEnv(env,
+		"CRON_RUN_ID="+c.RunID,
+		"CRON_NAMESPACE="+c.Monitor.Namespace,
+		fmt.Sprintf("CRON_START_TIME=%d", c.StartTime.Unix()),
+		fmt.Sprintf("CRON_DEADLINE=%d", c.deadline().Unix()),
+		fmt.Sprintf("CRON_ATTEMPT=%d", c.Attempt),
+	)
+
+	return env
+}
+
+// jobCommand returns a command of the job other than the command itself,
+// CRON_REQUIRE_CMD or a hook, run through sh as the user and with the env
+// and working directory the command gets. Like the command, whatever it
+// started is killed with it once ctx is done.
+func (c *Cron) jobCommand(ctx context.Context, command string, vars ...string) (*exec.Cmd, error) {
+	as, err := lookupRunAs()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Cancel = func() error {
+		return killTree(cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = outputWaitDelay
+	cmd.Env = setEnv(c.jobEnv(as), vars...)
+	cmd.Dir = c.options.dir
+	if as != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: as.credential()}
+	}
+	return cmd, nil
+}
+
+// checkEnv validates the CRON_ENV_ALLOW and CRON_ENV_DENY globs
+func checkEnv() error {
+	for _, pattern := range slices.Concat(config.CRON_ENV_ALLOW, config.CRON_ENV_DENY) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid env var pattern: %s", pattern)
+		}
+	}
+	return nil
+}
+
+// inheritedEnv returns the runner's env vars the command inherits, in login
+// mode only the allowed ones are. The runner's own CRON_* config isn't the
+// command's, those are only inherited when explicitly allowed.
+func inheritedEnv(login bool) []string {
+	var env []string
+
+	for _, v := range os.Environ() {
+		name, _, _ := strings.Cut(v, "=")
+		if matchEnv(config.CRON_ENV_DENY, name) {
+			continue
+		}
+		if strings.HasPrefix(name, "CRON_") && !matchEnv(config.CRON_ENV_ALLOW, name) {
+			continue
+		}
+		if (login || len(config.CRON_ENV_ALLOW) > 0) && !matchEnv(config.CRON_ENV_ALLOW, name) {
+			continue
+		}
+		env = append(env, v)
+	}
+
+	return env
+}
+
+// matchEnv reports whether the env var name matches one of the globs
+func matchEnv(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loginEnv returns the vars login(1) sets for the user, the runner's own user
+// if the command doesn't run as another one
+func loginEnv(as *runAs) []string {
+	if as == nil {
+		as = currentRunAs()
+	}
+
+	if as == nil {
+		return []string{"PATH=" + loginPath}
+	}
+	if as.uid == 0 {
+		return append(as.env(), "PATH="+loginRootPath)
+	}
+	return append(as.env(), "PATH="+loginPath)
+}
+
+// augmentPath adds CRON_PATH_PREPEND and CRON_PATH_APPEND around the PATH
+func augmentPath(env []string) []string {
+	if len(config.CRON_PATH_PREPEND) == 0 && len(config.CRON_PATH_APPEND) == 0 {
+		return env
+	}
+
+	dirs := slices.Clone(config.CRON_PATH_PREPEND)
+	if value, ok := getEnv(env, "PATH"); ok && value != "" {
+		dirs = append(dirs, value)
+	}
+	dirs = append(dirs, config.CRON_PATH_APPEND...)
+
+	return setEnv(env, "PATH="+strings.Join(dirs, string(os.PathListSeparator)))
+}
+
+// setEnv sets the KEY=VALUE assignments, replacing an earlier value in place
+// so each var is only listed once
+func setEnv(env []string, vars ...string) []string {
+	for _, v := range vars {
+		name, _, _ := strings.Cut(v, "=")
+		i := slices.IndexFunc(env, func(e string) bool {
+			return strings.HasPrefix(e, name+"=")
+		})
+		if i >= 0 {
+			env[i] = v
+		} else {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+// getEnv returns the value of the var in env
+func getEnv(env []string, name string) (string, bool) {
+	for _, v := range env {
+		if value, ok := strings.CutPrefix(v, name+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// lookPath finds the command in the PATH it's started with, which isn't the
+// runner's when CRON_PATH_* or CRON_ENV_* change it
+func lookPath(file, pathList string) (string, error) {
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			// an empty entry is the working directory, exec refuses those
+			continue
+		}
+		candidate := filepath.Join(dir, file)
+		if info, err := os.Stat(candidate); err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0 {
+			return candidate, nil
+		}
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
+
+// loadEnvFiles reads the CRON_ENV_FILE dotenv files in order
+func loadEnvFiles(paths []string) ([]string, error) {
+	var env []string
+
+	for _, filename := range paths {
+		vars, err := readEnvFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		env = setEnv(env, vars...)
+	}
+
+	return env, nil
+}
+
+// readEnvFile parses a dotenv file: KEY=VALUE lines, optionally prefixed with
+// export, and # comments. Single quoted values are taken literally, double
+// quoted ones understand \n, \t, \" and \\. Nothing is expanded.
+func readEnvFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRON_ENV_FILE: %w", err)
+	}
+	defer file.Close()
+
+	var env []string
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid CRON_ENV_FILE %s:%d: expected KEY=VALUE", filename, number)
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRON_ENV_FILE %s:%d: %v", filename, number, err)
+		}
+
+		env = setEnv(env, name+"="+value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid CRON_ENV_FILE %s: %v", filename, err)
+	}
+
+	return env, nil
+}
+
+// parseEnvValue unquotes the value of a dotenv line
+func parseEnvValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		return value[1 : end+1], nil
+
+	case strings.HasPrefix(value, `"`):
+		var b strings.Builder
+		for i := 1; i < len(value); i++ {
+			switch value[i] {
+			case '"':
+				return b.String(), nil
+			case '\\':
+				if i++; i == len(value) {
+					break
+				}
+				switch value[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(value[i])
+				}
+			default:
+				b.WriteByte(value[i])
+			}
+		}
+		return "", fmt.Errorf("unterminated quote")
+	}
+
+	// unquoted values end at a comment
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
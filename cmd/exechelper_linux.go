@@ -0,0 +1,179 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// the runner re-executes itself under this name to set the rlimits and umask
+// of the command right before executing it, os/exec has no way to do it
+// between fork and exec
+const execHelperName = "cron-runner-exec"
+
+// what the helper failed at
+const (
+	execStageOptions    = "options"
+	execStageCredential = "credential"
+	execStageExec       = "exec"
+)
+
+// execRequest is what the helper is asked to do, passed as its only argument
+type execRequest struct {
+	Path       string              `json:"path"`
+	Args       []string            `json:"args"`
+	Rlimits    []execRlimit        `json:"rlimits"`
+	Umask      *int                `json:"umask,omitempty"`
+	Credential *syscall.Credential `json:"credential,omitempty"`
+	Status     int                 `json:"status"` // fd a failure is reported to, closed on exec
+}
+
+type execRlimit struct {
+	Name     string `json:"name"`
+	Resource int    `json:"resource"`
+	Soft     uint64 `json:"soft"`
+	Hard     uint64 `json:"hard"`
+}
+
+// execFailure is reported by the helper when it couldn't execute the command
+type execFailure struct {
+	Stage   string `json:"stage"`
+	Errno   int    `json:"errno"`
+	Message string `json:"message"`
+}
+
+func init() {
+	if len(os.Args) == 2 && os.Args[0] == execHelperName {
+		runExecHelper(os.Args[1])
+	}
+}
+
+// runExecHelper sets the rlimits and umask, drops privileges and executes the command,
+// it only returns by exiting if any of that failed
+func runExecHelper(arg string) {
+	var req execRequest
+	if err := json.Unmarshal([]byte(arg), &req); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", execHelperName, err)
+		os.Exit(CRON_EXITCODE_EXEC_NOT_FOUND)
+	}
+
+	status := os.NewFile(uintptr(req.Status), "status")
+	syscall.CloseOnExec(req.Status)
+	fail := func(stage string, err error) {
+		var errno syscall.Errno
+		errors.As(err, &errno)
+		json.NewEncoder(status).Encode(execFailure{Stage: stage, Errno: int(errno), Message: err.Error()})
+		os.Exit(CRON_EXITCODE_EXEC_NOT_FOUND)
+	}
+
+	// syscall's own, the runtime puts its original RLIMIT_NOFILE back on exec otherwise
+	for _, limit := range req.Rlimits {
+		if err := syscall.Setrlimit(limit.Resource, &syscall.Rlimit{Cur: limit.Soft, Max: limit.Hard}); err != nil {
+			fail(execStageOptions, fmt.Errorf("error setting rlimit %s: %v", limit.Name, err))
+		}
+	}
+
+	if req.Umask != nil {
+		syscall.Umask(*req.Umask)
+	}
+
+	if cred := req.Credential; cred != nil {
+		groups := make([]int, len(cred.Groups))
+		for i, gid := range cred.Groups {
+			groups[i] = int(gid)
+		}
+		if err := syscall.Setgroups(groups); err != nil {
+			fail(execStageCredential, fmt.Errorf("error setting groups: %w", err))
+		}
+		if err := syscall.Setgid(int(cred.Gid)); err != nil {
+			fail(execStageCredential, fmt.Errorf("error setting gid: %w", err))
+		}
+		if err := syscall.Setuid(int(cred.Uid)); err != nil {
+			fail(execStageCredential, fmt.Errorf("error setting uid: %w", err))
+		}
+	}
+
+	fail(execStageExec, syscall.Exec(req.Path, req.Args, os.Environ()))
+}
+
+// execHelper is a command started through the helper
+type execHelper struct {
+	path   string
+	args   []string
+	status *os.File // read end, EOF once the command was executed
+	report *os.File // write end, passed to the helper
+}
+
+// wrapExec starts the command through the helper, which sets the rlimits and umask
+func wrapExec(cmd *exec.Cmd, o *processOptions) (*execHelper, error) {
+	status, report, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	req := execRequest{Path: cmd.Path, Args: cmd.Args, Umask: o.umask, Status: 3 + len(cmd.ExtraFiles)}
+	for _, limit := range o.rlimits {
+		req.Rlimits = append(req.Rlimits, execRlimit{limit.name, limit.resource, limit.soft, limit.hard})
+	}
+
+	// started privileged so that it can raise hard limits, it drops them itself
+	if cmd.SysProcAttr != nil && cmd.SysProcAttr.Credential != nil {
+		req.Credential = cmd.SysProcAttr.Credential
+		cmd.SysProcAttr.Credential = nil
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		status.Close()
+		report.Close()
+		return nil, err
+	}
+
+	helper := &execHelper{path: cmd.Path, args: cmd.Args, status: status, report: report}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, report)
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = []string{execHelperName, string(data)}
+
+	return helper, nil
+}
+
+// restore puts the command back the way it was once it's started, the path is
+// needed to tell why it couldn't be
+func (h *execHelper) restore(cmd *exec.Cmd) {
+	cmd.Path, cmd.Args = h.path, h.args
+	cmd.ExtraFiles = cmd.ExtraFiles[:len(cmd.ExtraFiles)-1]
+	h.report.Close()
+}
+
+// wait returns once the helper executed the command, or why it couldn't
+func (h *execHelper) wait(cmd *exec.Cmd) error {
+	defer h.status.Close()
+
+	var failure execFailure
+	err := json.NewDecoder(h.status).Decode(&failure)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	// the helper exits right after reporting
+	cmd.Wait()
+
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w: %v", errProcessOptions, err)
+	case failure.Stage == execStageExec:
+		// the way os/exec reports it, so that it's classified the same
+		return &fs.PathError{Op: "fork/exec", Path: h.path, Err: syscall.Errno(failure.Errno)}
+	case failure.Stage == execStageCredential:
+		return errors.New(failure.Message)
+	default:
+		return fmt.Errorf("%w: %s", errProcessOptions, failure.Message)
+	}
+}
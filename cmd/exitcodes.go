@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CustomExitCode gives an exit code of the command a name and the status it maps to
+type CustomExitCode struct {
+	ExitCode
+	Status int `json:"status"`
+}
+
+// the statuses a custom exit code can map to
+var customExitStatuses = []int{CRON_STATUS_SUCCESS, CRON_STATUS_WARNING, CRON_STATUS_FAIL}
+
+// parseExitCodes parses CRON_EXIT_CODES, a comma separated list of
+// <code>:<name>[:<status>] ie: 3:PARTIAL_DATA:WARNING,75:TEMPFAIL
+// the status defaults to SUCCESS for 0 and FAIL for anything else
+func parseExitCodes(entries []string) ([]CustomExitCode, error) {
+	var exitCodes []CustomExitCode
+
+	for _, entry := range entries {
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid CRON_EXIT_CODES entry %q: expected <code>:<name>[:<status>]", entry)
+		}
+
+		code, err := strconv.Atoi(parts[0])
+		if err != nil || code < 0 || code > 255 {
+			return nil, fmt.Errorf("invalid CRON_EXIT_CODES entry %q: exit code must be 0-255", entry)
+		}
+		if slices.ContainsFunc(exitCodes, func(exit CustomExitCode) bool { return exit.Code == code }) {
+			return nil, fmt.Errorf("invalid CRON_EXIT_CODES entry %q: exit code %d is already named", entry, code)
+		}
+
+		name := strings.ToUpper(parts[1])
+		if ok, _ := regexp.MatchString("^[A-Z0-9_]+$", name); !ok {
+			return nil, fmt.Errorf("invalid CRON_EXIT_CODES entry %q: name must be letters, digits and underscores", entry)
+		}
+
+		status := CRON_STATUS_FAIL
+		if code == CRON_EXITCODE_SUCCESS {
+			status = CRON_STATUS_SUCCESS
+		}
+		if len(parts) == 3 {
+			if status, err = parseExitStatus(parts[2]); err != nil {
+				return nil, fmt.Errorf("invalid CRON_EXIT_CODES entry %q: %v", entry, err)
+			}
+		}
+
+		exitCodes = append(exitCodes, CustomExitCode{ExitCode{code, name}, status})
+	}
+
+	return exitCodes, nil
+}
+
+// parseExitStatus returns the status code of a status name a custom exit code can map to
+func parseExitStatus(name string) (int, error) {
+	for _, status := range customExitStatuses {
+		if statusCodetoName[status] == strings.ToUpper(name) {
+			return status, nil
+		}
+	}
+
+	names := make([]string, len(customExitStatuses))
+	for i, status := range customExitStatuses {
+		names[i] = statusCodetoName[status]
+	}
+
+	return 0, fmt.Errorf("status must be one of %s", strings.Join(names, ", "))
+}
+
+// customExitCode returns the custom exit code for a code, if there is one
+func (c *Cron) customExitCode(code int) (CustomExitCode, bool) {
+	for _, exit := range c.exitCodes {
+		if exit.Code == code {
+			return exit, true
+		}
+	}
+	return CustomExitCode{}, false
+}
+
+// exitCodeTable returns the built-in exit codes merged with the custom ones
+func (c *Cron) exitCodeTable() []ExitCode {
+	var table []ExitCode
+
+	for _, exit := range EXIT_CODES {
+		if _, custom := c.customExitCode(exit.Code); !custom {
+			table = append(table, exit)
+		}
+	}
+
+	for _, exit := range c.exitCodes {
+		table = append(table, exit.ExitCode)
+	}
+
+	return table
+}
+
+// applyExitCodes maps the exit code of a command that ran to completion to
+// its custom status, so an expected nonzero exit can count as a success or warning
+func (c *Cron) applyExitCodes() {
+	if c.StatusCode != CRON_STATUS_SUCCESS && c.StatusCode != CRON_STATUS_FAIL {
+		return
+	}
+
+	// only real exit codes of the command, not signals or launch failures
+	if c.Signal != "" || c.LaunchFailure != "" {
+		return
+	}
+
+	if exit, ok := c.customExitCode(c.ExitCode); ok {
+		c.StatusCode = exit.Status
+	}
+}
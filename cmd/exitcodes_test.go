@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestCustomExitCodeWarning(t *testing.T) {
+	setConfig(t, &config.CRON_EXIT_CODES, []string{"3:partial_data:warning", "75:TEMPFAIL"})
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"sh", "-c", "exit 3"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_WARNING {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_WARNING, cron.StatusCode)
+	}
+
+	if cron.ExitCode != 3 || cron.GetExitCodeName() != "PARTIAL_DATA" {
+		t.Errorf("Expected exit code 3 (PARTIAL_DATA), got %d (%s)", cron.ExitCode, cron.GetExitCodeName())
+	}
+
+	if cron.GetExitCodeName(75) != "TEMPFAIL" {
+		t.Errorf("Expected exit code name %s, got %s", "TEMPFAIL", cron.GetExitCodeName(75))
+	}
+}
+
+func TestCustomExitCodeSuccess(t *testing.T) {
+	setConfig(t, &config.CRON_EXIT_CODES, []string{"1:NOTHING_TO_DO:SUCCESS"})
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"false"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+
+	// built-in names are replaced by the job's own
+	if cron.GetExitCodeName() != "NOTHING_TO_DO" {
+		t.Errorf("Expected exit code name %s, got %s", "NOTHING_TO_DO", cron.GetExitCodeName())
+	}
+
+	for _, exit := range cron.exitCodeTable() {
+		if exit.Code == CRON_EXITCODE_FAIL_GENERIC && exit.Name != "NOTHING_TO_DO" {
+			t.Errorf("Expected exit code table to only have the custom name for 1, got %s", exit.Name)
+		}
+	}
+}
+
+func TestCustomExitCodeFail(t *testing.T) {
+	setConfig(t, &config.CRON_EXIT_CODES, []string{"0:EMPTY:FAIL"})
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"true"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_FAIL, cron.StatusCode)
+	}
+}
+
+// TestCustomExitCodeNotForLaunchFailures tests that only real exit codes of the command are mapped
+func TestCustomExitCodeNotForLaunchFailures(t *testing.T) {
+	setConfig(t, &config.CRON_EXIT_CODES, []string{"127:MISSING:SUCCESS"})
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"invalidornonexistentcommand"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_FAIL, cron.StatusCode)
+	}
+}
+
+func TestCustomExitCodeInvalid(t *testing.T) {
+	for _, entry := range []string{"3", "x:NAME", "300:NAME", "3:bad-name", "3:NAME:TIMEOUT", "3:NAME:FAIL:X"} {
+		setConfig(t, &config.CRON_EXIT_CODES, []string{entry})
+
+		if _, err := New([]string{"true"}); err == nil {
+			t.Errorf("Expected an error for CRON_EXIT_CODES=%s, got nil", entry)
+		}
+	}
+
+	// a code can only have one name
+	setConfig(t, &config.CRON_EXIT_CODES, []string{"3:A", "3:B"})
+	if _, err := New([]string{"true"}); err == nil {
+		t.Errorf("Expected an error for duplicate codes, got nil")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+	"github.com/devinodaniel/cron-go/cmd/monitor"
+)
+
+// HOOKS
+// exposed as the hook label of the cron_hook_* metrics
+const (
+	CRON_HOOK_PRE        = "pre"
+	CRON_HOOK_POST       = "post"
+	CRON_HOOK_ON_SUCCESS = "on_success"
+	CRON_HOOK_ON_FAILURE = "on_failure"
+	CRON_HOOK_ON_TIMEOUT = "on_timeout"
+)
+
+// PRE HOOK POLICIES
+const (
+	CRON_HOOK_PRE_ABORT    = "abort"    // the command doesn't run if the pre hook failed
+	CRON_HOOK_PRE_CONTINUE = "continue" // the command runs anyway
+)
+
+// HookRun is how a CRON_HOOK_* command went
+type HookRun struct {
+	Hook       string `json:"hook"`       // pre, post, on_success, on_failure or on_timeout
+	StatusCode int    `json:"statusCode"` // 0: success, 1: fail, 2: timeout
+	ExitCode   int    `json:"exitCode"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// hook is a configured CRON_HOOK_* command
+type hook struct {
+	name    string
+	command string
+}
+
+// hooks returns the configured hooks in the order they'd run
+func hooks() []hook {
+	var hooks []hook
+	for _, h := range []hook{
+		{CRON_HOOK_PRE, config.CRON_HOOK_PRE},
+		{CRON_HOOK_ON_SUCCESS, config.CRON_HOOK_ON_SUCCESS},
+		{CRON_HOOK_ON_FAILURE, config.CRON_HOOK_ON_FAILURE},
+		{CRON_HOOK_ON_TIMEOUT, config.CRON_HOOK_ON_TIMEOUT},
+		{CRON_HOOK_POST, config.CRON_HOOK_POST},
+	} {
+		if h.command != "" {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+// checkHooks validates the CRON_HOOK_* config
+func checkHooks() error {
+	switch config.CRON_HOOK_PRE_POLICY {
+	case CRON_HOOK_PRE_ABORT, CRON_HOOK_PRE_CONTINUE:
+	default:
+		return fmt.Errorf("invalid CRON_HOOK_PRE_POLICY: %s", config.CRON_HOOK_PRE_POLICY)
+	}
+
+	if config.CRON_HOOK_TIMEOUT <= 0 {
+		return fmt.Errorf("invalid CRON_HOOK_TIMEOUT: %d", config.CRON_HOOK_TIMEOUT)
+	}
+	return nil
+}
+
+// hookEnv returns the vars a hook gets on top of the job's env: the run and
+// its result so far
+func (c *Cron) hookEnv(name string) []string {
+	return []string{
+		"CRON_HOOK=" + name,
+		"CRON_STATUS=" + c.GetStatusCodeName(),
+		fmt.Sprintf("CRON_STATUS_CODE=%d", c.StatusCode),
+		fmt.Sprintf("CRON_EXIT_CODE=%d", c.ExitCode),
+		fmt.Sprintf("CRON_DURATION_MS=%d", c.commandDuration().Milliseconds()),
+	}
+}
+
+// runHook runs the hook command the way the command would run, its output is
+// passed on like the command's, and returns whether it succeeded
+func (c *Cron) runHook(name, command string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.CRON_HOOK_TIMEOUT)*time.Second)
+	defer cancel()
+
+	cmd, err := c.jobCommand(ctx, command, c.hookEnv(name)...)
+	if err != nil {
+		c.logf("WARNING: %s hook not run: %v\n", name, err)
+		c.Hooks = append(c.Hooks, HookRun{Hook: name, StatusCode: CRON_STATUS_FAIL, ExitCode: CRON_EXITCODE_INVALID_USER})
+		return false
+	}
+
+	stdout := newOutputStream("stdout", os.Stdout, c.outputRedactor())
+	stderr := newOutputStream("stderr", os.Stderr, c.outputRedactor())
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	start := time.Now()
+	err = cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+
+	run := HookRun{
+		Hook:       name,
+		StatusCode: CRON_STATUS_SUCCESS,
+		ExitCode:   CRON_EXITCODE_SUCCESS,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		run.StatusCode = CRON_STATUS_FAIL
+		run.ExitCode = CRON_EXITCODE_UNKNOWN
+		if status, signaled := waitStatus(err); signaled {
+			run.ExitCode = 128 + int(status.Signal())
+		} else if cmd.ProcessState != nil {
+			run.ExitCode = cmd.ProcessState.ExitCode()
+		}
+
+		if ctx.Err() == context.DeadlineExceeded {
+			run.StatusCode = CRON_STATUS_TIMEOUT
+			c.logf("WARNING: %s hook timed out after %ds\n", name, config.CRON_HOOK_TIMEOUT)
+		} else {
+			c.logf("WARNING: %s hook failed: %v\n", name, err)
+		}
+	}
+
+	c.Hooks = append(c.Hooks, run)
+	return run.StatusCode == CRON_STATUS_SUCCESS
+}
+
+// applyPreHook runs CRON_HOOK_PRE and returns false if the command shouldn't
+// run because it failed
+func (c *Cron) applyPreHook() bool {
+	if config.CRON_HOOK_PRE == "" {
+		return true
+	}
+
+	if c.runHook(CRON_HOOK_PRE, config.CRON_HOOK_PRE) || config.CRON_HOOK_PRE_POLICY == CRON_HOOK_PRE_CONTINUE {
+		return true
+	}
+
+	c.logf("ERROR: pre hook failed, the command didn't run\n")
+	c.ExitCode, c.StatusCode = CRON_EXITCODE_PRE_HOOK_FAILED, CRON_STATUS_FAIL
+	return false
+}
+
+// runPostHooks runs the hook for how the run went, then CRON_HOOK_POST, a
+// failing hook doesn't change the status of the run
+func (c *Cron) runPostHooks() {
+	var outcome hook
+	switch c.StatusCode {
+	case CRON_STATUS_SUCCESS, CRON_STATUS_WARNING:
+		outcome = hook{CRON_HOOK_ON_SUCCESS, config.CRON_HOOK_ON_SUCCESS}
+	case CRON_STATUS_TIMEOUT:
+		outcome = hook{CRON_HOOK_ON_TIMEOUT, config.CRON_HOOK_ON_TIMEOUT}
+		if outcome.command == "" {
+			outcome = hook{CRON_HOOK_ON_FAILURE, config.CRON_HOOK_ON_FAILURE}
+		}
+	default:
+		outcome = hook{CRON_HOOK_ON_FAILURE, config.CRON_HOOK_ON_FAILURE}
+	}
+
+	if outcome.command != "" {
+		c.runHook(outcome.name, outcome.command)
+	}
+	if config.CRON_HOOK_POST != "" {
+		c.runHook(CRON_HOOK_POST, config.CRON_HOOK_POST)
+	}
+}
+
+// setHookMetrics sets how each hook that ran went
+func (c *Cron) setHookMetrics() {
+	for _, run := range c.Hooks {
+		monitor.CronHookStatusCode.WithLabelValues(c.Monitor.Namespace, run.Hook).Set(float64(run.StatusCode))
+		monitor.CronHookExitCode.WithLabelValues(c.Monitor.Namespace, run.Hook).Set(float64(run.ExitCode))
+		monitor.CronHookDurationMilliseconds.WithLabelValues(c.Monitor.Namespace, run.Hook).Set(float64(run.DurationMs))
+	}
+}
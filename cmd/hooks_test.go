@@ -0,0 +1,222 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// logHook returns a hook command that appends what it was told to the log
+func logHook(log string) string {
+	return `echo "$CRON_HOOK $CRON_STATUS $CRON_STATUS_CODE $CRON_EXIT_CODE" >> ` + log
+}
+
+// hookLog returns the lines the hooks logged
+func hookLog(log string) []string {
+	data, _ := os.ReadFile(log)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// hookNames returns the hooks that ran, in order
+func hookNames(cron *Cron) string {
+	var names []string
+	for _, run := range cron.Hooks {
+		names = append(names, run.Hook)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestRunHooksSuccess(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	log := filepath.Join(t.TempDir(), "hooks")
+	setConfig(t, &config.CRON_HOOK_PRE, logHook(log))
+	setConfig(t, &config.CRON_HOOK_ON_SUCCESS, logHook(log))
+	setConfig(t, &config.CRON_HOOK_ON_FAILURE, logHook(log))
+	setConfig(t, &config.CRON_HOOK_POST, logHook(log)+`; test "$CRON_DURATION_MS" -ge 100`)
+
+	cron, _ := New([]string{"sh", "-c", "sleep 0.1; echo command >> " + log})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Fatalf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+
+	expected := []string{"pre RUNNING 4 -1", "command", "on_success SUCCESS 0 0", "post SUCCESS 0 0"}
+	if lines := hookLog(log); strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %q, got %q", expected, lines)
+	}
+	if names := hookNames(cron); names != "pre,on_success,post" {
+		t.Errorf("Expected the pre, on_success and post hooks, got %s", names)
+	}
+	for _, run := range cron.Hooks {
+		if run.StatusCode != CRON_STATUS_SUCCESS || run.ExitCode != 0 {
+			t.Errorf("Expected the %s hook to succeed, got %+v", run.Hook, run)
+		}
+	}
+}
+
+func TestRunHooksFailure(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	log := filepath.Join(t.TempDir(), "hooks")
+	setConfig(t, &config.CRON_HOOK_ON_SUCCESS, logHook(log))
+	setConfig(t, &config.CRON_HOOK_ON_FAILURE, logHook(log)+"; exit 5")
+
+	cron, _ := New([]string{"sh", "-c", "exit 3"})
+	cron.Run()
+
+	// a failing hook doesn't change how the run went
+	if cron.StatusCode != CRON_STATUS_FAIL || cron.ExitCode != 3 {
+		t.Errorf("Expected status code %d and exit code 3, got %d and %d", CRON_STATUS_FAIL, cron.StatusCode, cron.ExitCode)
+	}
+	if lines := hookLog(log); len(lines) != 1 || lines[0] != "on_failure FAIL 1 3" {
+		t.Errorf("Expected only the on_failure hook, got %q", lines)
+	}
+	if len(cron.Hooks) != 1 || cron.Hooks[0].StatusCode != CRON_STATUS_FAIL || cron.Hooks[0].ExitCode != 5 {
+		t.Errorf("Expected the on_failure hook to fail with 5, got %+v", cron.Hooks)
+	}
+}
+
+func TestRunHooksTimeout(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	log := filepath.Join(t.TempDir(), "hooks")
+	setConfig(t, &config.CRON_TIMEOUT, 1)
+	setConfig(t, &config.CRON_HOOK_ON_FAILURE, logHook(log))
+
+	// on_failure stands in for on_timeout when it isn't set
+	cron, _ := New([]string{"sleep", "10"})
+	cron.Run()
+
+	if lines := hookLog(log); len(lines) != 1 || lines[0] != "on_failure TIMEOUT 2 1" {
+		t.Errorf("Expected the on_failure hook, got %q", lines)
+	}
+
+	os.Remove(log)
+	setConfig(t, &config.CRON_HOOK_ON_TIMEOUT, logHook(log))
+	cron, _ = New([]string{"sleep", "10"})
+	cron.Run()
+
+	if lines := hookLog(log); len(lines) != 1 || lines[0] != "on_timeout TIMEOUT 2 1" {
+		t.Errorf("Expected only the on_timeout hook, got %q", lines)
+	}
+}
+
+func TestRunHooksAsJob(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("dropping privileges needs root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no nobody user")
+	}
+
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_USER, "nobody")
+	t.Setenv("SSH_AUTH_SOCK", "/tmp/ssh-root/agent")
+	setConfig(t, &config.CRON_ENV_FILE, []string{writeEnvFile(t, "ENV_TEST_HOOK=yes\n")})
+
+	log := filepath.Join(nobodyWritableDir(t), "hooks")
+
+	// same user and env as the command, not the runner's
+	setConfig(t, &config.CRON_HOOK_POST, `echo "$(id -u) $ENV_TEST_HOOK ${SSH_AUTH_SOCK-unset} $CRON_HOOK" > `+log)
+	cron, _ := New([]string{"true"})
+	cron.Run()
+
+	if lines := hookLog(log); len(lines) != 1 || lines[0] != nobody.Uid+" yes unset post" {
+		t.Errorf("Expected the post hook to run as nobody with the job's env, got %q", lines)
+	}
+}
+
+func TestRunHookTimedOut(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_HOOK_TIMEOUT, 1)
+	setConfig(t, &config.CRON_HOOK_POST, "sleep 10")
+
+	cron, _ := New([]string{"true"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+	if len(cron.Hooks) != 1 || cron.Hooks[0].StatusCode != CRON_STATUS_TIMEOUT {
+		t.Fatalf("Expected the post hook to time out, got %+v", cron.Hooks)
+	}
+	if cron.Hooks[0].DurationMs > 5000 {
+		t.Errorf("Expected the post hook to be killed right away, took %dms", cron.Hooks[0].DurationMs)
+	}
+}
+
+func TestRunPreHookAbort(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	log := filepath.Join(t.TempDir(), "hooks")
+	setConfig(t, &config.CRON_HOOK_PRE, "exit 1")
+	setConfig(t, &config.CRON_HOOK_ON_FAILURE, logHook(log))
+	setConfig(t, &config.CRON_HOOK_POST, logHook(log))
+
+	cron, _ := New([]string{"sh", "-c", "echo command >> " + log})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_FAIL || cron.ExitCode != CRON_EXITCODE_PRE_HOOK_FAILED {
+		t.Errorf("Expected status code %d and exit code %d, got %d and %d", CRON_STATUS_FAIL, CRON_EXITCODE_PRE_HOOK_FAILED, cron.StatusCode, cron.ExitCode)
+	}
+
+	expected := []string{"on_failure FAIL 1 -13", "post FAIL 1 -13"}
+	if lines := hookLog(log); strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected the command not to run, got %q", lines)
+	}
+}
+
+func TestRunPreHookContinue(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_HOOK_PRE, "exit 1")
+	setConfig(t, &config.CRON_HOOK_PRE_POLICY, CRON_HOOK_PRE_CONTINUE)
+
+	cron, _ := New([]string{"true"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS || cron.ExitCode != CRON_EXITCODE_SUCCESS {
+		t.Errorf("Expected status code %d and exit code %d, got %d and %d", CRON_STATUS_SUCCESS, CRON_EXITCODE_SUCCESS, cron.StatusCode, cron.ExitCode)
+	}
+	if len(cron.Hooks) != 1 || cron.Hooks[0].StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected the failed pre hook to be recorded, got %+v", cron.Hooks)
+	}
+}
+
+func TestRunHookMetrics(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE, "hook_metrics")
+
+	setConfig(t, &config.CRON_HOOK_ON_FAILURE, "exit 4")
+	cron, _ := New([]string{"false"})
+	cron.Run()
+
+	metrics, _ := os.ReadFile(filepath.Join(config.CRON_METRICS_DIR, "cron_hook_metrics_metrics.prom"))
+	for _, expected := range []string{
+		`cron_hook_status_code{hook="on_failure",namespace="hook_metrics"} 1`,
+		`cron_hook_exit_code{hook="on_failure",namespace="hook_metrics"} 4`,
+		`cron_hook_duration_milliseconds{hook="on_failure",namespace="hook_metrics"}`,
+	} {
+		if !strings.Contains(string(metrics), expected) {
+			t.Errorf("Expected %s in the metrics", expected)
+		}
+	}
+}
+
+func TestCheckHooksInvalid(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+
+	setConfig(t, &config.CRON_HOOK_PRE_POLICY, "ignore")
+	if _, err := New([]string{"true"}); err == nil {
+		t.Errorf("Expected an error for CRON_HOOK_PRE_POLICY=ignore, got nil")
+	}
+
+	setConfig(t, &config.CRON_HOOK_PRE_POLICY, CRON_HOOK_PRE_ABORT)
+	setConfig(t, &config.CRON_HOOK_TIMEOUT, 0)
+	if _, err := New([]string{"true"}); err == nil {
+		t.Errorf("Expected an error for CRON_HOOK_TIMEOUT=0, got nil")
+	}
+}
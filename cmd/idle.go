@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// errIdle is the cause of the cancellation when the command stopped writing output
+var errIdle = errors.New("no output")
+
+// idleWatch calls expire once neither stdout nor stderr were written to for
+// CRON_IDLE_TIMEOUT seconds, counting from started
+func (c *Cron) idleWatch(started time.Time, done <-chan struct{}, expire func()) {
+	timeout := time.Duration(config.CRON_IDLE_TIMEOUT) * time.Second
+
+	ticker := time.NewTicker(min(timeout/4, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if time.Since(c.lastOutput(started)) > timeout {
+				expire()
+				return
+			}
+		}
+	}
+}
+
+// lastOutput returns when the command last wrote any output, or since if it hasn't yet
+func (c *Cron) lastOutput(since time.Time) time.Time {
+	last := since
+
+	for _, stream := range []*outputStream{c.stdout, c.stderr} {
+		if written, _ := stream.last(); written.After(last) {
+			last = written
+		}
+	}
+
+	return last
+}
+
+// lastOutputLine returns the most recent line of output of either stream
+func (c *Cron) lastOutputLine() string {
+	var last time.Time
+	var line string
+
+	for _, stream := range []*outputStream{c.stdout, c.stderr} {
+		if written, l := stream.last(); l != "" && !written.Before(last) {
+			last, line = written, l
+		}
+	}
+
+	return line
+}
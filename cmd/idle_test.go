@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestIdleTimeoutHung(t *testing.T) {
+	setConfig(t, &config.CRON_IDLE_TIMEOUT, 1)
+	setConfig(t, &config.CRON_REDACT_REGEX, `token=(\w+)`)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"sh", "-c", "echo 'connecting with token=s3cr3t'; printf 'waiting for reply'; sleep 10"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_HUNG {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_HUNG, cron.StatusCode)
+	}
+	if cron.ExitCode != CRON_EXITCODE_SIG_KILL {
+		t.Errorf("Expected exit code %d, got %d", CRON_EXITCODE_SIG_KILL, cron.ExitCode)
+	}
+	if cron.LastOutput != "waiting for reply" {
+		t.Errorf("Expected the unfinished line as last output, got %q", cron.LastOutput)
+	}
+	if cron.Duration > 5*time.Second {
+		t.Errorf("Expected the command to be killed after ~1s, took %v", cron.Duration)
+	}
+}
+
+func TestIdleTimeoutLastLineRedacted(t *testing.T) {
+	setConfig(t, &config.CRON_IDLE_TIMEOUT, 1)
+	setConfig(t, &config.CRON_REDACT_REGEX, `token=(\w+)`)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"sh", "-c", "echo 'connecting with token=s3cr3t' >&2; sleep 10"})
+	cron.Run()
+
+	if cron.LastOutput != "connecting with token="+REDACTED {
+		t.Errorf("Expected the redacted last line, got %q", cron.LastOutput)
+	}
+}
+
+func TestIdleTimeoutKeepsTalking(t *testing.T) {
+	setConfig(t, &config.CRON_IDLE_TIMEOUT, 1)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	// 2s in total but never more than 0.5s without output
+	cron, _ := New([]string{"sh", "-c", "for i in 1 2 3 4; do echo $i; sleep 0.5; done"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+	if cron.LastOutput != "" {
+		t.Errorf("Expected no last output for a command that wasn't hung, got %q", cron.LastOutput)
+	}
+}
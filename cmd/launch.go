@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// LAUNCH FAILURE REASONS
+// exposed as the reason label of the cron_launch_failure metric
+const (
+	CRON_LAUNCH_NOT_FOUND       = "not_found"
+	CRON_LAUNCH_PERM_DENIED     = "permission_denied"
+	CRON_LAUNCH_EXEC_FORMAT     = "exec_format"
+	CRON_LAUNCH_BAD_INTERPRETER = "bad_interpreter"
+	CRON_LAUNCH_TOO_MANY_FILES  = "too_many_open_files"
+	CRON_LAUNCH_NO_MEMORY       = "out_of_memory"
+	CRON_LAUNCH_PROCESS_LIMIT   = "process_limit"
+	CRON_LAUNCH_ARG_TOO_LONG    = "arg_list_too_long"
+	CRON_LAUNCH_TEXT_BUSY       = "text_file_busy"
+	CRON_LAUNCH_BAD_WORKDIR     = "bad_workdir"
+	CRON_LAUNCH_PROCESS_OPTIONS = "process_options"
+	CRON_LAUNCH_UNKNOWN         = "unknown"
+)
+
+// launchFailures maps the errors returned when a command can't be started
+// to an exit code and reason, the first match wins
+var launchFailures = []struct {
+	err      error
+	exitCode int
+	reason   string
+}{
+	{errProcessOptions, CRON_EXITCODE_PROCESS_OPTIONS, CRON_LAUNCH_PROCESS_OPTIONS},
+	{exec.ErrNotFound, CRON_EXITCODE_EXEC_NOT_FOUND, CRON_LAUNCH_NOT_FOUND},
+	{fs.ErrPermission, CRON_EXITCODE_PERM_DENIED, CRON_LAUNCH_PERM_DENIED},
+	{syscall.ENOEXEC, CRON_EXITCODE_EXEC_FORMAT, CRON_LAUNCH_EXEC_FORMAT},
+	{syscall.ENOTDIR, CRON_EXITCODE_EXEC_NOT_FOUND, CRON_LAUNCH_NOT_FOUND},
+	{syscall.EMFILE, CRON_EXITCODE_TOO_MANY_FILES, CRON_LAUNCH_TOO_MANY_FILES},
+	{syscall.ENFILE, CRON_EXITCODE_TOO_MANY_FILES, CRON_LAUNCH_TOO_MANY_FILES},
+	{syscall.ENOMEM, CRON_EXITCODE_NO_MEMORY, CRON_LAUNCH_NO_MEMORY},
+	{syscall.EAGAIN, CRON_EXITCODE_PROCESS_LIMIT, CRON_LAUNCH_PROCESS_LIMIT},
+	{syscall.E2BIG, CRON_EXITCODE_ARG_TOO_LONG, CRON_LAUNCH_ARG_TOO_LONG},
+	{syscall.ETXTBSY, CRON_EXITCODE_TEXT_BUSY, CRON_LAUNCH_TEXT_BUSY},
+}
+
+// launchFailure classifies the error of a command that never started in dir
+// as the user, nil for the runner's own
+func launchFailure(err error, path, dir string, as *runAs) (int, string) {
+	// the working directory is changed to before the command is executed
+	if dir != "" && (errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOTDIR) || errors.Is(err, fs.ErrPermission)) {
+		if !canEnter(dir, as) {
+			return CRON_EXITCODE_BAD_WORKDIR, CRON_LAUNCH_BAD_WORKDIR
+		}
+	}
+
+	// ENOENT for a file that exists means the interpreter in its shebang doesn't
+	if errors.Is(err, syscall.ENOENT) {
+		if _, statErr := os.Stat(path); statErr == nil {
+			return CRON_EXITCODE_BAD_INTERPRETER, CRON_LAUNCH_BAD_INTERPRETER
+		}
+		return CRON_EXITCODE_EXEC_NOT_FOUND, CRON_LAUNCH_NOT_FOUND
+	}
+
+	for _, failure := range launchFailures {
+		if errors.Is(err, failure.err) {
+			return failure.exitCode, failure.reason
+		}
+	}
+
+	return CRON_EXITCODE_UNKNOWN, CRON_LAUNCH_UNKNOWN
+}
+
+// canEnter tells whether the user can change to dir. access(2) checks as the
+// runner, so for another user every directory on the way is checked against
+// its ids from the permission bits, ACLs aren't taken into account.
+func canEnter(dir string, as *runAs) bool {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return false
+	}
+	if as == nil {
+		return unix.Access(dir, unix.X_OK) == nil
+	}
+	if as.uid == 0 {
+		return true
+	}
+
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+
+	for {
+		var stat unix.Stat_t
+		if err := unix.Stat(dir, &stat); err != nil {
+			return false
+		}
+
+		var search uint32
+		switch {
+		case stat.Uid == as.uid:
+			search = unix.S_IXUSR
+		case stat.Gid == as.gid || slices.Contains(as.groups, stat.Gid):
+			search = unix.S_IXGRP
+		default:
+			search = unix.S_IXOTH
+		}
+		if uint32(stat.Mode)&search == 0 {
+			return false
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return true
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// writeExecutable writes an executable file for the test to run
+func writeExecutable(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatalf("Expected no error writing %s, got %v", path, err)
+	}
+	return path
+}
+
+func testLaunchFailure(t *testing.T, args []string, exitCode int, reason string) {
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New(args)
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_FAIL, cron.StatusCode)
+	}
+
+	if cron.ExitCode != exitCode {
+		t.Errorf("Expected exit code %d, got %d (%s)", exitCode, cron.ExitCode, cron.GetExitCodeName())
+	}
+
+	if cron.LaunchFailure != reason {
+		t.Errorf("Expected launch failure %s, got %s", reason, cron.LaunchFailure)
+	}
+}
+
+func TestLaunchFailureNotFound(t *testing.T) {
+	testLaunchFailure(t, []string{"invalidornonexistentcommand"}, CRON_EXITCODE_EXEC_NOT_FOUND, CRON_LAUNCH_NOT_FOUND)
+}
+
+func TestLaunchFailureNotFoundPath(t *testing.T) {
+	testLaunchFailure(t, []string{"/tmp/does_not_exist/script.sh"}, CRON_EXITCODE_EXEC_NOT_FOUND, CRON_LAUNCH_NOT_FOUND)
+}
+
+func TestLaunchFailurePermissionDenied(t *testing.T) {
+	testLaunchFailure(t, []string{"/dev/null"}, CRON_EXITCODE_PERM_DENIED, CRON_LAUNCH_PERM_DENIED)
+}
+
+func TestLaunchFailureBadInterpreter(t *testing.T) {
+	script := writeExecutable(t, "#!/tmp/does_not_exist/interpreter\necho hello\n")
+	testLaunchFailure(t, []string{script}, CRON_EXITCODE_BAD_INTERPRETER, CRON_LAUNCH_BAD_INTERPRETER)
+}
+
+func TestLaunchFailureExecFormat(t *testing.T) {
+	script := writeExecutable(t, "\x00\x01\x02\x03 not a binary")
+	testLaunchFailure(t, []string{script}, CRON_EXITCODE_EXEC_FORMAT, CRON_LAUNCH_EXEC_FORMAT)
+}
+
+// TestLaunchedCommandFailure tests that a command that started and failed isn't a launch failure
+func TestLaunchedCommandFailure(t *testing.T) {
+	testLaunchFailure(t, []string{"sh", "-c", "exit 3"}, 3, "")
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -15,17 +20,64 @@ import (
 	"github.com/devinodaniel/cron-go/cmd/monitor"
 
 	"github.com/google/uuid"
+	"golang.org/x/sys/unix"
+
+	io_prometheus_client "github.com/prometheus/client_model/go"
 )
 
 type Cron struct {
-	StartTime  time.Time     `json:"startTime"`
-	EndTime    time.Time     `json:"endTime"`
-	StatusCode int           `json:"statusCode"` // 0: success, 1: fail, 2: timeout, 3: terminated
-	ExitCode   int           `json:"exitCode"`   // command exit code, -1 if not set or unknown
-	Monitor    Monitor       `json:"monitor"`
-	Timeout    time.Duration `json:"timeout"`
-	Duration   time.Duration `json:"duration"`
-	Args       []string      `json:"args"`
+	RunID         string          `json:"runId"` // unique per execution, ties metrics, logs and reports together
+	StartTime     time.Time       `json:"startTime"`
+	EndTime       time.Time       `json:"endTime"`
+	StatusCode    int             `json:"statusCode"` // 0: success, 1: fail, 2: timeout, 3: terminated
+	ExitCode      int             `json:"exitCode"`   // command exit code, -1 if not set or unknown
+	Monitor       Monitor         `json:"monitor"`
+	Timeout       time.Duration   `json:"timeout"`
+	Duration      time.Duration   `json:"duration"`
+	Args          []string        `json:"args"`
+	Attempt       int             `json:"attempt"`          // the runner doesn't retry, so this is always 1
+	Signal        string          `json:"signal,omitempty"` // signal that killed the command, ie: SIGKILL
+	CoreDumped    bool            `json:"coreDumped"`
+	LaunchFailure string          `json:"launchFailure,omitempty"` // why the command could not be started
+	OutputCheck   *OutputCheck    `json:"outputCheck,omitempty"`   // why the output of the command failed the run
+	Notify        *NotifyState    `json:"notify,omitempty"`        // what the command reported over the notification socket
+	LastOutput    string          `json:"lastOutput,omitempty"`    // last line the command wrote before it was killed as hung
+	Usage         *ResourceUsage  `json:"usage,omitempty"`         // rusage of the command once it exited
+	Peaks         *ResourceSample `json:"peaks,omitempty"`         // peak resource usage sampled from /proc while it ran
+	Cgroup        *CgroupUsage    `json:"cgroup,omitempty"`        // accounting of the run's own cgroup
+	User          string          `json:"user,omitempty"`          // CRON_USER the command ran as
+	Scratch       *ScratchDir     `json:"scratch,omitempty"`       // CRON_SCRATCH, the run's private temp directory
+	Precondition  *Precondition   `json:"precondition,omitempty"`  // the CRON_REQUIRE_* check that kept the command from running
+	Hooks         []HookRun       `json:"hooks,omitempty"`         // the CRON_HOOK_* commands that ran, in order
+
+	setupErr       error            // set when the cron refused to start, no metrics are written
+	redactor       *Redactor        // hides secrets in everything the runner prints or persists
+	exitCodes      []CustomExitCode // CRON_EXIT_CODES, the job's own exit code names
+	outputCheck    *outputCheck     // CRON_OUTPUT_*, success criteria based on the output
+	outputPatterns *outputPatterns  // CRON_OUTPUT_PATTERNS, counts of matching lines
+	stdout, stderr *outputStream    // the command's output, nil until it runs
+	processState   *os.ProcessState // set once the command exited
+	sampler        *sampler         // samples the command's process tree while it runs
+	options        *processOptions  // CRON_CHDIR, CRON_NICE, ... how the command is spawned
+	runAs          *runAs           // CRON_USER, nil if the command runs as the runner's user
+	envFile        []string         // CRON_ENV_FILE, the KEY=VALUE vars added to the command's env
+	command        string           // CRON_SHELL, the command string the shell runs
+	scratch        string           // CRON_SCRATCH, path of the run's private temp directory
+	preconditions  []requirement    // CRON_REQUIRE_*, checked before the command runs
+	notifier       *notifier        // CRON_NOTIFY, the command's progress and heartbeats
+	stopper        *stopper         // the runner's own SIGINT or SIGTERM, received while the run goes on
+
+	childMetricsPath string                               // CRON_METRICS_OUTPUT, where the command publishes its own metrics
+	childMetrics     []*io_prometheus_client.MetricFamily // the command's own metrics, prefixed and namespaced
+	cmdStart, cmdEnd time.Time                            // the command's own run, its timeout starts with it
+}
+
+// MarshalJSON serializes the cron with its secrets redacted
+func (c *Cron) MarshalJSON() ([]byte, error) {
+	type cron Cron
+	redacted := cron(*c)
+	redacted.Args = c.redactor.Args(c.Args)
+	return json.Marshal(redacted)
 }
 
 type Monitor struct {
@@ -52,6 +104,16 @@ const (
 	CRON_STATUS_TIMEOUT    = 2
 	CRON_STATUS_TERMINATED = 3
 	CRON_STATUS_RUNNING    = 4
+	CRON_STATUS_WARNING    = 5
+	CRON_STATUS_WATCHDOG   = 6 // the command stopped sending heartbeats and was killed
+	CRON_STATUS_HUNG       = 7 // the command stopped writing output and was killed
+	CRON_STATUS_OOM_KILLED = 8 // the command failed because the OOM killer killed one of its processes
+
+	// the runner refused to start the command
+
+	CRON_STATUS_INVALID_USER        = 9  // CRON_USER or one of its groups doesn't exist
+	CRON_STATUS_SKIPPED             = 10 // a CRON_REQUIRE_* precondition isn't met, nothing to do
+	CRON_STATUS_PRECONDITION_FAILED = 11 // a CRON_REQUIRE_* precondition isn't met and CRON_REQUIRE_ACTION=fail
 )
 
 var (
@@ -62,6 +124,13 @@ var (
 		{CRON_STATUS_TIMEOUT, "TIMEOUT"},
 		{CRON_STATUS_TERMINATED, "TERMINATED"},
 		{CRON_STATUS_RUNNING, "RUNNING"},
+		{CRON_STATUS_WARNING, "WARNING"},
+		{CRON_STATUS_WATCHDOG, "WATCHDOG"},
+		{CRON_STATUS_HUNG, "HUNG"},
+		{CRON_STATUS_OOM_KILLED, "OOM_KILLED"},
+		{CRON_STATUS_INVALID_USER, "INVALID_USER"},
+		{CRON_STATUS_SKIPPED, "SKIPPED"},
+		{CRON_STATUS_PRECONDITION_FAILED, "PRECONDITION_FAILED"},
 	}
 
 	statusCodetoName = make(map[int]string)
@@ -92,12 +161,76 @@ const (
 	CRON_EXITCODE_SUCCESS      = 0
 	CRON_EXITCODE_FAIL_GENERIC = 1
 
+	// the command could not be started, negative so they never clash with a real exit code
+
+	CRON_EXITCODE_EXEC_FORMAT     = -2
+	CRON_EXITCODE_BAD_INTERPRETER = -3
+	CRON_EXITCODE_TOO_MANY_FILES  = -4
+	CRON_EXITCODE_NO_MEMORY       = -5
+	CRON_EXITCODE_PROCESS_LIMIT   = -6
+	CRON_EXITCODE_ARG_TOO_LONG    = -7
+	CRON_EXITCODE_TEXT_BUSY       = -8
+
+	// the command exited successfully but its output failed the output check
+
+	CRON_EXITCODE_OUTPUT_CHECK = -9
+
+	// the working directory of the command doesn't exist or can't be entered
+
+	CRON_EXITCODE_BAD_WORKDIR = -10
+
+	// CRON_USER or one of its groups doesn't exist
+
+	CRON_EXITCODE_INVALID_USER = -11
+
+	// the scratch directory grew past CRON_SCRATCH_MAX
+
+	CRON_EXITCODE_SCRATCH_FULL = -12
+
+	// CRON_HOOK_PRE failed and CRON_HOOK_PRE_POLICY kept the command from running
+
+	CRON_EXITCODE_PRE_HOOK_FAILED = -13
+
+	// the nice, ionice, cpu affinity or rlimits of the command couldn't be applied
+
+	CRON_EXITCODE_PROCESS_OPTIONS = -14
+
 	// special exit codes (https://tldp.org/LDP/abs/html/exitcodes.html
 
 	CRON_EXITCODE_PERM_DENIED    = 126
 	CRON_EXITCODE_EXEC_NOT_FOUND = 127
 	CRON_EXITCODE_SIG_INT        = 130
 	CRON_EXITCODE_SIG_TERM       = 143
+
+	// a command killed by a signal exits with 128 + the signal number
+
+	CRON_EXITCODE_SIG_HUP    = 128 + int(syscall.SIGHUP)
+	CRON_EXITCODE_SIG_QUIT   = 128 + int(syscall.SIGQUIT)
+	CRON_EXITCODE_SIG_ILL    = 128 + int(syscall.SIGILL)
+	CRON_EXITCODE_SIG_TRAP   = 128 + int(syscall.SIGTRAP)
+	CRON_EXITCODE_SIG_ABRT   = 128 + int(syscall.SIGABRT)
+	CRON_EXITCODE_SIG_BUS    = 128 + int(syscall.SIGBUS)
+	CRON_EXITCODE_SIG_FPE    = 128 + int(syscall.SIGFPE)
+	CRON_EXITCODE_SIG_KILL   = 128 + int(syscall.SIGKILL)
+	CRON_EXITCODE_SIG_USR1   = 128 + int(syscall.SIGUSR1)
+	CRON_EXITCODE_SIG_SEGV   = 128 + int(syscall.SIGSEGV)
+	CRON_EXITCODE_SIG_USR2   = 128 + int(syscall.SIGUSR2)
+	CRON_EXITCODE_SIG_PIPE   = 128 + int(syscall.SIGPIPE)
+	CRON_EXITCODE_SIG_ALRM   = 128 + int(syscall.SIGALRM)
+	CRON_EXITCODE_SIG_CHLD   = 128 + int(syscall.SIGCHLD)
+	CRON_EXITCODE_SIG_CONT   = 128 + int(syscall.SIGCONT)
+	CRON_EXITCODE_SIG_STOP   = 128 + int(syscall.SIGSTOP)
+	CRON_EXITCODE_SIG_TSTP   = 128 + int(syscall.SIGTSTP)
+	CRON_EXITCODE_SIG_TTIN   = 128 + int(syscall.SIGTTIN)
+	CRON_EXITCODE_SIG_TTOU   = 128 + int(syscall.SIGTTOU)
+	CRON_EXITCODE_SIG_URG    = 128 + int(syscall.SIGURG)
+	CRON_EXITCODE_SIG_XCPU   = 128 + int(syscall.SIGXCPU)
+	CRON_EXITCODE_SIG_XFSZ   = 128 + int(syscall.SIGXFSZ)
+	CRON_EXITCODE_SIG_VTALRM = 128 + int(syscall.SIGVTALRM)
+	CRON_EXITCODE_SIG_PROF   = 128 + int(syscall.SIGPROF)
+	CRON_EXITCODE_SIG_WINCH  = 128 + int(syscall.SIGWINCH)
+	CRON_EXITCODE_SIG_IO     = 128 + int(syscall.SIGIO)
+	CRON_EXITCODE_SIG_SYS    = 128 + int(syscall.SIGSYS)
 )
 
 var (
@@ -105,10 +238,50 @@ var (
 		{CRON_EXITCODE_UNKNOWN, "UNKNOWN"},
 		{CRON_EXITCODE_SUCCESS, "SUCCESS"},
 		{CRON_EXITCODE_FAIL_GENERIC, "FAIL_GENERIC"},
+		{CRON_EXITCODE_EXEC_FORMAT, "EXEC_FORMAT"},
+		{CRON_EXITCODE_BAD_INTERPRETER, "BAD_INTERPRETER"},
+		{CRON_EXITCODE_TOO_MANY_FILES, "TOO_MANY_FILES"},
+		{CRON_EXITCODE_NO_MEMORY, "NO_MEMORY"},
+		{CRON_EXITCODE_PROCESS_LIMIT, "PROCESS_LIMIT"},
+		{CRON_EXITCODE_ARG_TOO_LONG, "ARG_TOO_LONG"},
+		{CRON_EXITCODE_TEXT_BUSY, "TEXT_BUSY"},
+		{CRON_EXITCODE_OUTPUT_CHECK, "OUTPUT_CHECK"},
+		{CRON_EXITCODE_BAD_WORKDIR, "BAD_WORKDIR"},
+		{CRON_EXITCODE_INVALID_USER, "INVALID_USER"},
+		{CRON_EXITCODE_SCRATCH_FULL, "SCRATCH_FULL"},
+		{CRON_EXITCODE_PRE_HOOK_FAILED, "PRE_HOOK_FAILED"},
+		{CRON_EXITCODE_PROCESS_OPTIONS, "PROCESS_OPTIONS"},
 		{CRON_EXITCODE_PERM_DENIED, "PERM_DENIED"},
 		{CRON_EXITCODE_EXEC_NOT_FOUND, "EXEC_NOT_FOUND"},
 		{CRON_EXITCODE_SIG_INT, "SIG_INT"},
 		{CRON_EXITCODE_SIG_TERM, "SIG_TERM"},
+		{CRON_EXITCODE_SIG_HUP, "SIG_HUP"},
+		{CRON_EXITCODE_SIG_QUIT, "SIG_QUIT"},
+		{CRON_EXITCODE_SIG_ILL, "SIG_ILL"},
+		{CRON_EXITCODE_SIG_TRAP, "SIG_TRAP"},
+		{CRON_EXITCODE_SIG_ABRT, "SIG_ABRT"},
+		{CRON_EXITCODE_SIG_BUS, "SIG_BUS"},
+		{CRON_EXITCODE_SIG_FPE, "SIG_FPE"},
+		{CRON_EXITCODE_SIG_KILL, "SIG_KILL"},
+		{CRON_EXITCODE_SIG_USR1, "SIG_USR1"},
+		{CRON_EXITCODE_SIG_SEGV, "SIG_SEGV"},
+		{CRON_EXITCODE_SIG_USR2, "SIG_USR2"},
+		{CRON_EXITCODE_SIG_PIPE, "SIG_PIPE"},
+		{CRON_EXITCODE_SIG_ALRM, "SIG_ALRM"},
+		{CRON_EXITCODE_SIG_CHLD, "SIG_CHLD"},
+		{CRON_EXITCODE_SIG_CONT, "SIG_CONT"},
+		{CRON_EXITCODE_SIG_STOP, "SIG_STOP"},
+		{CRON_EXITCODE_SIG_TSTP, "SIG_TSTP"},
+		{CRON_EXITCODE_SIG_TTIN, "SIG_TTIN"},
+		{CRON_EXITCODE_SIG_TTOU, "SIG_TTOU"},
+		{CRON_EXITCODE_SIG_URG, "SIG_URG"},
+		{CRON_EXITCODE_SIG_XCPU, "SIG_XCPU"},
+		{CRON_EXITCODE_SIG_XFSZ, "SIG_XFSZ"},
+		{CRON_EXITCODE_SIG_VTALRM, "SIG_VTALRM"},
+		{CRON_EXITCODE_SIG_PROF, "SIG_PROF"},
+		{CRON_EXITCODE_SIG_WINCH, "SIG_WINCH"},
+		{CRON_EXITCODE_SIG_IO, "SIG_IO"},
+		{CRON_EXITCODE_SIG_SYS, "SIG_SYS"},
 	}
 
 	exitCodetoName = make(map[int]string)
@@ -120,6 +293,10 @@ func (c *Cron) SetExitCode(code int) {
 		c.ExitCode = code
 		return
 	}
+	if _, exists := c.customExitCode(code); exists {
+		c.ExitCode = code
+		return
+	}
 }
 
 func (c *Cron) GetExitCodeName(code ...int) string {
@@ -127,6 +304,9 @@ func (c *Cron) GetExitCodeName(code ...int) string {
 	if len(code) > 0 {
 		exitCode = code[0]
 	}
+	if exit, exists := c.customExitCode(exitCode); exists {
+		return exit.Name
+	}
 	if name, exists := exitCodetoName[exitCode]; exists {
 		return name
 	}
@@ -157,13 +337,54 @@ func init() {
 	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronDryrun)
 	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronStatus)
 	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronExit)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronSignal)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronCoreDumped)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronLaunchFailure)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronOutputLines)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronOutputTotalLines)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronOutputBytes)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronProgressRatio)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronLastHeartbeatSeconds)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronElapsedSeconds)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronLastUpdateTimeSeconds)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronCPUSeconds)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronMemoryRSSBytes)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronCPUUserSeconds)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronCPUSystemSeconds)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronMaxRSSBytes)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronBlockIOOperations)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronContextSwitches)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronPageFaults)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronCPUPercent)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronProcesses)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronThreads)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronOpenFDs)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronIOBytes)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronPeakCPUPercent)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronPeakMemoryRSSBytes)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronPeakProcesses)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronPeakThreads)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronPeakOpenFDs)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronCgroupCPUSeconds)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronCgroupMemoryPeakBytes)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronOOMKills)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronScratchBytes)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronPreconditionFailed)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronHookStatusCode)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronHookExitCode)
+	monitor.PrometheusMetricsRegistry.MustRegister(monitor.CronHookDurationMilliseconds)
 }
 
 // usage prints how to use this little cron runner
 func usage() {
 	fmt.Println("Usage: cron-runner <any-command-or-script> [args]")
+	fmt.Println("       cron-runner --shell '<command string>'")
 	fmt.Println("Example: CRON_DRYRUN=true cron-runner echo 'hello world'")
 	fmt.Println("Example: cron-runner php /path/to/script.php")
+	fmt.Println("Example: cron-runner --shell 'pg_dump mydb | gzip > /backup/mydb.sql.gz'")
+	fmt.Println("\nCommands:")
+	fmt.Println("  help        print this message")
+	fmt.Println("  namespaces  list the namespaces registered in CRON_STATE_DIR")
 
 	// print the config options
 	// these should be set as global environment variables ieL profile
@@ -175,6 +396,70 @@ func usage() {
 	fmt.Printf("  CRON_METRICS_PREFIX: %s\n", config.CRON_METRICS_PREFIX)
 	fmt.Printf("  CRON_NAMESPACE: %s\n", config.CRON_NAMESPACE)
 	fmt.Printf("  CRON_DRYRUN: %t\n", config.CRON_DRYRUN)
+	fmt.Printf("  CRON_STATE_DIR: %s\n", config.CRON_STATE_DIR)
+	fmt.Printf("  CRON_NAMESPACE_COLLISION: %s\n", config.CRON_NAMESPACE_COLLISION)
+	fmt.Printf("  CRON_REDACT_FLAGS: %s\n", strings.Join(config.CRON_REDACT_FLAGS, ","))
+	fmt.Printf("  CRON_REDACT_REGEX: %s\n", config.CRON_REDACT_REGEX)
+	fmt.Printf("  CRON_REDACT_ENV: %s\n", strings.Join(config.CRON_REDACT_ENV, ","))
+	fmt.Printf("  CRON_REDACT_OUTPUT: %t\n", config.CRON_REDACT_OUTPUT)
+	fmt.Printf("  CRON_REPORT: %s\n", config.CRON_REPORT)
+	fmt.Printf("  CRON_EXIT_CODES: %s\n", strings.Join(config.CRON_EXIT_CODES, ","))
+	fmt.Printf("  CRON_OUTPUT_MUST_MATCH: %s\n", config.CRON_OUTPUT_MUST_MATCH)
+	fmt.Printf("  CRON_OUTPUT_MUST_NOT_MATCH: %s\n", config.CRON_OUTPUT_MUST_NOT_MATCH)
+	fmt.Printf("  CRON_STDERR_EMPTY: %t\n", config.CRON_STDERR_EMPTY)
+	fmt.Printf("  CRON_OUTPUT_PATTERNS: %s\n", config.CRON_OUTPUT_PATTERNS)
+	fmt.Printf("  CRON_CHILD_METRICS: %t\n", config.CRON_CHILD_METRICS)
+	fmt.Printf("  CRON_METRICS_REFRESH: %d\n", config.CRON_METRICS_REFRESH)
+	fmt.Printf("  CRON_IDLE_TIMEOUT: %d\n", config.CRON_IDLE_TIMEOUT)
+	fmt.Printf("  CRON_SAMPLE_INTERVAL: %d\n", config.CRON_SAMPLE_INTERVAL)
+	fmt.Printf("  CRON_CGROUP: %t\n", config.CRON_CGROUP)
+	fmt.Printf("  CRON_CGROUP_PARENT: %s\n", config.CRON_CGROUP_PARENT)
+	fmt.Printf("  CRON_MEMORY_MAX: %s\n", config.CRON_MEMORY_MAX)
+	fmt.Printf("  CRON_CPU_MAX: %s\n", config.CRON_CPU_MAX)
+	fmt.Printf("  CRON_PIDS_MAX: %d\n", config.CRON_PIDS_MAX)
+	fmt.Printf("  CRON_IO_MAX: %s\n", strings.Join(config.CRON_IO_MAX, ","))
+	fmt.Printf("  CRON_CHDIR: %s\n", config.CRON_CHDIR)
+	fmt.Printf("  CRON_UMASK: %s\n", config.CRON_UMASK)
+	fmt.Printf("  CRON_NICE: %s\n", config.CRON_NICE)
+	fmt.Printf("  CRON_IONICE: %s\n", config.CRON_IONICE)
+	fmt.Printf("  CRON_CPU_AFFINITY: %s\n", config.CRON_CPU_AFFINITY)
+	fmt.Printf("  CRON_RLIMIT_NOFILE: %s\n", config.CRON_RLIMIT_NOFILE)
+	fmt.Printf("  CRON_RLIMIT_NPROC: %s\n", config.CRON_RLIMIT_NPROC)
+	fmt.Printf("  CRON_RLIMIT_CORE: %s\n", config.CRON_RLIMIT_CORE)
+	fmt.Printf("  CRON_RLIMIT_AS: %s\n", config.CRON_RLIMIT_AS)
+	fmt.Printf("  CRON_USER: %s\n", config.CRON_USER)
+	fmt.Printf("  CRON_GROUP: %s\n", config.CRON_GROUP)
+	fmt.Printf("  CRON_GROUPS: %s\n", strings.Join(config.CRON_GROUPS, ","))
+	fmt.Printf("  CRON_ENV_FILE: %s\n", strings.Join(config.CRON_ENV_FILE, ","))
+	fmt.Printf("  CRON_ENV_ALLOW: %s\n", strings.Join(config.CRON_ENV_ALLOW, ","))
+	fmt.Printf("  CRON_ENV_DENY: %s\n", strings.Join(config.CRON_ENV_DENY, ","))
+	fmt.Printf("  CRON_PATH_PREPEND: %s\n", strings.Join(config.CRON_PATH_PREPEND, ","))
+	fmt.Printf("  CRON_PATH_APPEND: %s\n", strings.Join(config.CRON_PATH_APPEND, ","))
+	fmt.Printf("  CRON_ENV_LOGIN: %t\n", config.CRON_ENV_LOGIN)
+	fmt.Printf("  CRON_SHELL: %s\n", config.CRON_SHELL)
+	fmt.Printf("  CRON_SHELL_OPTIONS: %s\n", config.CRON_SHELL_OPTIONS)
+	fmt.Printf("  CRON_SCRATCH: %t\n", config.CRON_SCRATCH)
+	fmt.Printf("  CRON_SCRATCH_DIR: %s\n", config.CRON_SCRATCH_DIR)
+	fmt.Printf("  CRON_SCRATCH_MAX: %s\n", config.CRON_SCRATCH_MAX)
+	fmt.Printf("  CRON_SCRATCH_RETENTION_DAYS: %d\n", config.CRON_SCRATCH_RETENTION_DAYS)
+	fmt.Printf("  CRON_PTY: %t\n", config.CRON_PTY)
+	fmt.Printf("  CRON_REQUIRE_FILE: %s\n", strings.Join(config.CRON_REQUIRE_FILE, ","))
+	fmt.Printf("  CRON_REQUIRE_DISK: %s\n", strings.Join(config.CRON_REQUIRE_DISK, ","))
+	fmt.Printf("  CRON_REQUIRE_TCP: %s\n", strings.Join(config.CRON_REQUIRE_TCP, ","))
+	fmt.Printf("  CRON_REQUIRE_CMD: %s\n", config.CRON_REQUIRE_CMD)
+	fmt.Printf("  CRON_REQUIRE_TIMEOUT: %d\n", config.CRON_REQUIRE_TIMEOUT)
+	fmt.Printf("  CRON_REQUIRE_ACTION: %s\n", config.CRON_REQUIRE_ACTION)
+	fmt.Printf("  CRON_HOOK_PRE: %s\n", config.CRON_HOOK_PRE)
+	fmt.Printf("  CRON_HOOK_POST: %s\n", config.CRON_HOOK_POST)
+	fmt.Printf("  CRON_HOOK_ON_SUCCESS: %s\n", config.CRON_HOOK_ON_SUCCESS)
+	fmt.Printf("  CRON_HOOK_ON_FAILURE: %s\n", config.CRON_HOOK_ON_FAILURE)
+	fmt.Printf("  CRON_HOOK_ON_TIMEOUT: %s\n", config.CRON_HOOK_ON_TIMEOUT)
+	fmt.Printf("  CRON_HOOK_TIMEOUT: %d\n", config.CRON_HOOK_TIMEOUT)
+	fmt.Printf("  CRON_HOOK_PRE_POLICY: %s\n", config.CRON_HOOK_PRE_POLICY)
+	fmt.Printf("  CRON_STDIN: %s\n", config.CRON_STDIN)
+	fmt.Printf("  CRON_STDIN_CONTENT: %d bytes\n", len(config.CRON_STDIN_CONTENT))
+	fmt.Printf("  CRON_NOTIFY: %t\n", config.CRON_NOTIFY)
+	fmt.Printf("  CRON_WATCHDOG: %d\n", config.CRON_WATCHDOG)
 }
 
 func New(args []string) (*Cron, error) {
@@ -188,8 +473,97 @@ func New(args []string) (*Cron, error) {
 		os.Exit(0)
 	}
 
+	// if 'namespaces' is passed as an argument, print the namespace registry
+	if len(args) == 1 && args[0] == "namespaces" {
+		if err := listNamespaces(); err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	redactor, err := NewRedactor()
+	if err != nil {
+		return nil, err
+	}
+
+	// --shell 'pg_dump db | gzip > db.gz' runs through the shell
+	args, command, err := shellArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	exitCodes, err := parseExitCodes(config.CRON_EXIT_CODES)
+	if err != nil {
+		return nil, err
+	}
+
+	outputCheck, err := newOutputCheck()
+	if err != nil {
+		return nil, err
+	}
+
+	outputPatterns, err := parseOutputPatterns(config.CRON_OUTPUT_PATTERNS)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := cgroupLimits(); err != nil {
+		return nil, err
+	}
+
+	options, err := newProcessOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNamespaceCollision(); err != nil {
+		return nil, err
+	}
+
+	if err := checkRunAs(); err != nil {
+		return nil, err
+	}
+
+	if err := checkEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := checkStdin(); err != nil {
+		return nil, err
+	}
+
+	if _, err := scratchMax(); err != nil {
+		return nil, err
+	}
+
+	preconditions, err := parsePreconditions()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkHooks(); err != nil {
+		return nil, err
+	}
+
+	envFile, err := loadEnvFiles(config.CRON_ENV_FILE)
+	if err != nil {
+		return nil, err
+	}
+	redactor.addEnv(envFile)
+
 	return &Cron{
-		Args: args,
+		Args:           args,
+		Attempt:        1,
+		redactor:       redactor,
+		exitCodes:      exitCodes,
+		outputCheck:    outputCheck,
+		outputPatterns: outputPatterns,
+		options:        options,
+		envFile:        envFile,
+		command:        command,
+		preconditions:  preconditions,
+		stopper:        &stopper{},
 	}, nil
 }
 
@@ -213,7 +587,9 @@ func (c *Cron) Run() error {
 	// Wait for either the cron job to finish or a signal to be received
 	select {
 	case sig := <-sigs:
-		c.terminated(sig)
+		// kill the command and let the run wrap up as terminated
+		c.stopper.stop(sig)
+		<-done
 	case <-done:
 		// Cron job completed successfully
 	}
@@ -231,6 +607,7 @@ func (c *Cron) Run() error {
 // which is set in the metadata
 func (c *Cron) start() {
 	// set the start metadata
+	c.RunID = uuid.New().String()
 	c.StartTime = time.Now()
 	c.StatusCode = CRON_STATUS_RUNNING
 	c.ExitCode = CRON_EXITCODE_UNKNOWN
@@ -246,12 +623,48 @@ func (c *Cron) start() {
 		if c.Monitor.Prefix != "" {
 			fmt.Printf("DRYRUN: Metric Prefix: %s\n", c.Monitor.Prefix)
 		}
-		fmt.Printf("DRYRUN: Metric Namespace: %s\n", c.Monitor.Prometheus.Namespace)
-		fmt.Printf("DRYRUN: Args: %v\n", c.Args)
+		fmt.Printf("DRYRUN: Metric Namespace: %s\n", c.Monitor.Namespace)
+		fmt.Printf("DRYRUN: Run ID: %s\n", c.RunID)
+		fmt.Printf("DRYRUN: Args: %v\n", c.redactor.Args(c.Args))
 		fmt.Printf("DRYRUN: Timeout: %v\n", config.CRON_TIMEOUT)
+		c.options.dryrun()
+		dryrunStdin()
+		for _, p := range c.preconditions {
+			fmt.Printf("DRYRUN: Precondition: %s\n", p.reason)
+		}
+		for _, hook := range hooks() {
+			fmt.Printf("DRYRUN: Hook: %s: %s\n", hook.name, c.redactor.String(hook.command))
+		}
+		if scratchEnabled() {
+			fmt.Printf("DRYRUN: Scratch: %s\n", filepath.Join(scratchParent(), scratchPrefix+"..."))
+		}
+		if config.CRON_USER != "" {
+			fmt.Printf("DRYRUN: User: %s\n", config.CRON_USER)
+		}
+		if config.CRON_GROUP != "" {
+			fmt.Printf("DRYRUN: Group: %s\n", config.CRON_GROUP)
+		}
+		if len(config.CRON_GROUPS) > 0 {
+			fmt.Printf("DRYRUN: Groups: %s\n", strings.Join(config.CRON_GROUPS, ","))
+		}
+		runAs, _ := lookupRunAs()
+		env := c.redactor.Env(c.childEnv(runAs))
+		slices.Sort(env)
+		for _, v := range env {
+			fmt.Printf("DRYRUN: Env: %s\n", v)
+		}
 		return
 	}
 
+	// make sure no other command is writing to the same metrics file
+	if config.CRON_METRICS {
+		if err := c.claimNamespace(); err != nil {
+			c.StatusCode = CRON_STATUS_FAIL
+			c.setupErr = err
+			return
+		}
+	}
+
 	// write metrics file so we know its running
 	if config.CRON_METRICS {
 
@@ -260,11 +673,93 @@ func (c *Cron) start() {
 		monitor.CronTimeoutSeconds.WithLabelValues(c.Monitor.Namespace).Set(float64(config.CRON_TIMEOUT))
 		monitor.CronDryrun.WithLabelValues(c.Monitor.Namespace).Set(float64(boolToInt(config.CRON_DRYRUN)))
 
-		c.writeMetrics()
+		if err := c.writeMetrics(); err != nil {
+			c.logf("WARNING: %v\n", err)
+		}
+	}
+
+	// never fall back to running anything as root if the user doesn't exist,
+	// the hooks would run as that user too
+	if !c.applyRunAs() {
+		return
+	}
+
+	// only if there's something to do
+	if !c.applyPreconditions() {
+		// a skipped run didn't happen as far as the hooks are concerned
+		if c.StatusCode != CRON_STATUS_SKIPPED {
+			c.runPostHooks()
+		}
+		return
+	}
+
+	// get things ready for the command, it may not run if that failed
+	if !c.applyPreHook() {
+		c.runPostHooks()
+		return
 	}
 
 	// execute the command and get the exit code
-	c.ExitCode, c.StatusCode = run_cmd(c.Args)
+	c.ExitCode, c.StatusCode = c.run_cmd()
+
+	// the runner itself was told to stop, whatever the command made of it
+	if sig := c.stopper.stopped(); sig != nil {
+		c.terminated(sig)
+	}
+
+	// pick up the metrics the command published
+	c.collectChildMetrics()
+
+	// tell an OOM kill apart from any other failure
+	c.applyCgroup()
+
+	// let the job decide what its exit code means
+	c.applyExitCodes()
+
+	// and whether its output means it failed anyway
+	c.applyOutputCheck()
+
+	// clean up after it, unless it failed
+	c.applyScratch()
+
+	// and let the hooks clean up or alert on how it went
+	c.runPostHooks()
+}
+
+// stopper passes the runner's own SIGINT or SIGTERM on to the command, the
+// run itself goes on in its own goroutine and wraps up as terminated
+type stopper struct {
+	mu     sync.Mutex
+	signal os.Signal               // nil until received
+	cancel context.CancelCauseFunc // kills the running command, nil until it runs
+}
+
+// stop kills the command, if it's running
+func (s *stopper) stop(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.signal = sig
+	if s.cancel != nil {
+		s.cancel(errTerminated)
+	}
+}
+
+// attach makes stop kill the command, false if the signal already came
+func (s *stopper) attach(cancel context.CancelCauseFunc) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cancel = cancel
+	return s.signal == nil
+}
+
+// stopped returns the signal the runner received, nil if none
+func (s *stopper) stopped() os.Signal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.signal
 }
 
 // terminated() updates the metadata after the command has been terminated
@@ -290,56 +785,336 @@ func (c *Cron) finish() error {
 	// calculate the duration
 	c.Duration = c.EndTime.Sub(c.StartTime)
 
-	if config.CRON_METRICS {
+	var errs []error
+
+	// the cron never started, don't touch a metrics file it doesn't own
+	if c.setupErr != nil {
+		errs = append(errs, c.setupErr)
+	} else if config.CRON_METRICS {
 		// set the additional metrics
 		monitor.CronEndTimeSeconds.WithLabelValues(c.Monitor.Namespace).Set(float64(c.EndTime.Unix()))
 		monitor.CronStatusCode.WithLabelValues(c.Monitor.Namespace).Set(float64(c.StatusCode))
 		monitor.CronExitCode.WithLabelValues(c.Monitor.Namespace).Set(float64(c.ExitCode))
 		monitor.CronDurationMilliseconds.WithLabelValues(c.Monitor.Namespace).Set(float64(c.Duration.Milliseconds()))
+		monitor.CronCoreDumped.WithLabelValues(c.Monitor.Namespace).Set(boolToInt(c.CoreDumped))
+		if c.Signal != "" {
+			monitor.CronSignal.WithLabelValues(c.Monitor.Namespace, c.Signal).Set(1)
+		}
+		if c.LaunchFailure != "" {
+			monitor.CronLaunchFailure.WithLabelValues(c.Monitor.Namespace, c.LaunchFailure).Set(1)
+		}
+		c.setOutputMetrics()
+		c.setNotifyMetrics()
+		c.setFinalRefreshMetrics()
+		c.setUsageMetrics()
+		c.setPeakMetrics()
+		c.setCgroupMetrics()
+		c.setScratchMetrics()
+		c.setPreconditionMetrics()
+		c.setHookMetrics()
 
 		if err := c.writeMetrics(); nil != err {
-			return err
+			errs = append(errs, err)
 		}
 	}
 
-	return nil
+	// whatever went wrong, the runs that failed are the ones a report is needed for
+	if config.CRON_REPORT != "" {
+		if err := c.writeReport(); nil != err {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
+// commandDuration returns how long the command itself ran so far, 0 if it
+// never started
+func (c *Cron) commandDuration() time.Duration {
+	switch {
+	case c.cmdStart.IsZero():
+		return 0
+	case c.cmdEnd.IsZero():
+		return time.Since(c.cmdStart)
+	}
+	return c.cmdEnd.Sub(c.cmdStart)
+}
+
+// deadline returns when the command is killed on CRON_TIMEOUT, when it would
+// be if it started now when it hasn't yet
+func (c *Cron) deadline() time.Time {
+	start := c.cmdStart
+	if start.IsZero() {
+		start = time.Now()
+	}
+	return start.Add(time.Duration(config.CRON_TIMEOUT) * time.Second)
+}
+
+// errTerminated is the cause of the cancellation when the runner itself
+// received SIGINT or SIGTERM
+var errTerminated = errors.New("runner terminated")
+
+// how long to wait for the output to close after the command exited
+const outputWaitDelay = 5 * time.Second
+
 // run_cmd() executes the cron job and returns the exit code and status code
-func run_cmd(args []string) (int, int) {
-	// create a context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(),
-		time.Duration(config.CRON_TIMEOUT)*time.Second)
-	defer cancel()
+func (c *Cron) run_cmd() (int, int) {
+	args := c.Args
+
+	// the command's own run starts here, the hooks before it don't count
+	c.cmdStart = time.Now()
+	defer func() { c.cmdEnd = time.Now() }()
+
+	// create a context with a timeout, the watchdog can cancel it early
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	// and so can the runner's own SIGINT or SIGTERM, unless it already came
+	if !c.stopper.attach(cancel) {
+		return CRON_EXITCODE_UNKNOWN, CRON_STATUS_TERMINATED
+	}
+	ctx, cancelTimeout := context.WithDeadline(ctx, c.deadline())
+	defer cancelTimeout()
 
 	// config the command with context
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 
-	// redirect stdout to os.Stdout
-	cmd.Stdout = os.Stdout
+	// kill whatever the command started too, not just the command itself
+	cmd.Cancel = func() error {
+		return killTree(cmd.Process.Pid, syscall.SIGKILL)
+	}
 
-	// redirect stderr to os.Stderr
-	cmd.Stderr = os.Stderr
+	// drop privileges to the user resolved before anything ran
+	if c.runAs != nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: c.runAs.credential()}
+	}
+
+	// give it a private temp directory, kept if the run fails
+	if scratchEnabled() {
+		sweepScratch()
+		if path, err := c.createScratch(); err != nil {
+			c.logf("WARNING: running without a scratch directory: %v\n", err)
+		} else {
+			c.scratch = path
+		}
+	}
+
+	// give the command a file to publish its own metrics to, as a path and as fd 3
+	if file := c.openChildMetrics(); file != nil {
+		defer file.Close()
+		cmd.ExtraFiles = []*os.File{file}
+	}
+
+	// let the command know which run it is part of
+	cmd.Env = c.childEnv(c.runAs)
+
+	// look the command up in its own PATH if it isn't the runner's
+	if path, ok := getEnv(cmd.Env, "PATH"); ok && path != os.Getenv("PATH") && !strings.Contains(args[0], "/") {
+		cmd.Path, cmd.Err = lookPath(args[0], path)
+	}
+
+	// run it from CRON_CHDIR, the runner's working directory if empty
+	cmd.Dir = c.options.dir
+
+	// feed it CRON_STDIN or CRON_STDIN_CONTENT, /dev/null by default
+	stdin, err := openStdin()
+	if err != nil {
+		c.logf("ERROR: %v\n", err)
+		return CRON_EXITCODE_UNKNOWN, CRON_STATUS_FAIL
+	}
+	if file, ok := stdin.(*os.File); ok && file != os.Stdin {
+		defer file.Close()
+	}
+	cmd.Stdin = stdin
+
+	// give the command a socket to report its progress and heartbeats to
+	notifier, err := c.startNotify()
+	if err != nil {
+		c.logf("WARNING: notification socket disabled: %v\n", err)
+	}
+	if notifier != nil {
+		c.notifier = notifier
+		cmd.Env = append(cmd.Env, notifier.childEnv()...)
+
+		served := make(chan struct{})
+		go func() {
+			notifier.serve(c)
+			close(served)
+		}()
+
+		done := make(chan struct{})
+		if config.CRON_WATCHDOG > 0 {
+			go notifier.watchdog(done, func() { cancel(errWatchdog) })
+		}
+
+		// stop listening before the run is finished so the metrics aren't
+		// rewritten behind its back
+		defer func() {
+			close(done)
+			notifier.stop()
+			<-served
+			notifier.close()
+
+			state := notifier.snapshot()
+			c.Notify = &state
+		}()
+	}
+
+	observers := []lineObserver{c.outputPatterns}
+	if c.outputCheck != nil {
+		observers = append(observers, c.outputCheck)
+	}
+
+	// redirect stdout and stderr to os.Stdout and os.Stderr through a line
+	// scanner so that every line can be counted and checked on the way
+	c.stdout = newOutputStream("stdout", os.Stdout, c.outputRedactor(), observers...)
+	c.stderr = newOutputStream("stderr", os.Stderr, c.outputRedactor(), observers...)
+	defer c.stdout.Flush()
+	defer c.stderr.Flush()
+	cmd.Stdout, cmd.Stderr = c.stdout, c.stderr
+
+	// or through a terminal, for tools that behave differently without one
+	var term *terminal
+	if config.CRON_PTY {
+		if term, err = c.attachPty(cmd); err != nil {
+			c.logf("WARNING: running without a terminal: %v\n", err)
+		} else {
+			defer term.close()
+			c.stdout.terminal = true
+		}
+	}
+
+	// don't wait forever on background processes that inherited the output
+	cmd.WaitDelay = outputWaitDelay
+
+	// put the run in a cgroup of its own to limit and account for everything it starts
+	if cgroupEnabled() {
+		cg, err := newCgroup(fmt.Sprintf("%.200s-%.8s", c.Monitor.Namespace, c.RunID))
+		if err != nil {
+			c.logf("WARNING: running without a cgroup: %v\n", err)
+		} else {
+			cg.attach(cmd)
+			defer func() {
+				if cmd.ProcessState != nil {
+					c.Cgroup = cg.usage()
+				}
+				if err := cg.remove(); err != nil {
+					c.logf("WARNING: cgroup left behind: %v\n", err)
+				}
+			}()
+		}
+	}
 
 	// run it!
-	if err := cmd.Run(); err != nil {
+	err = c.options.start(cmd)
+	launched := err == nil
+	if launched {
+		started := time.Now()
+		if term != nil {
+			term.started(c.stdout)
+		}
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+
+		// watch what it uses while it runs
+		c.sampler = newSampler(cmd.Process.Pid, started)
+		if config.CRON_SAMPLE_INTERVAL > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.sampler.run(done)
+			}()
+		}
+
+		// keep the metrics fresh while it runs
+		if config.CRON_METRICS && config.CRON_METRICS_REFRESH > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.refreshMetrics(done)
+			}()
+		}
+
+		// or fills its scratch directory
+		if max, _ := scratchMax(); c.scratch != "" && max > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				scratchWatch(c.scratch, max, done, func() { cancel(errScratchFull) })
+			}()
+		}
+
+		// and kill it if it goes quiet for too long
+		if config.CRON_IDLE_TIMEOUT > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.idleWatch(started, done, func() { cancel(errIdle) })
+			}()
+		}
+
+		err = cmd.Wait()
+		if term != nil {
+			term.wait(outputWaitDelay)
+		}
+		close(done)
+		wg.Wait()
+		c.processState = cmd.ProcessState
+		c.Usage = c.resourceUsage()
+		c.Peaks = c.sampler.peaks()
+	}
+	if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		// the command never started, find out why instead of guessing from the error text
+		if !launched {
+			exitCode, reason := launchFailure(err, cmd.Path, cmd.Dir, c.runAs)
+			c.LaunchFailure = reason
+			if errors.Is(err, errProcessOptions) {
+				c.logf("ERROR: %v\n", err)
+			}
+			return exitCode, CRON_STATUS_FAIL
+		}
+
+		// record the signal that killed the command, even if it was us on timeout
+		status, signaled := waitStatus(err)
+		if signaled {
+			c.Signal = unix.SignalName(status.Signal())
+			c.CoreDumped = status.CoreDump()
+		}
+
 		// check if the context deadline was exceeded
 		if ctx.Err() == context.DeadlineExceeded {
 			return CRON_EXITCODE_FAIL_GENERIC, CRON_STATUS_TIMEOUT
 		}
 
-		// we do specific circumstance failure checking here
-		// to be able to return the desired corresponding exit code
-		// maybe there is a better way to do this but it works for now
+		// or the command stopped sending heartbeats
+		if errors.Is(context.Cause(ctx), errWatchdog) {
+			c.logf("WARNING: no heartbeat for %ds, killed the command\n", config.CRON_WATCHDOG)
+			if signaled {
+				return 128 + int(status.Signal()), CRON_STATUS_WATCHDOG
+			}
+			return CRON_EXITCODE_UNKNOWN, CRON_STATUS_WATCHDOG
+		}
+
+		// or the command went quiet, probably stuck on something
+		if errors.Is(context.Cause(ctx), errIdle) {
+			c.LastOutput = c.redactor.String(c.lastOutputLine())
+			c.logf("WARNING: no output for %ds, killed the command\n", config.CRON_IDLE_TIMEOUT)
+			if signaled {
+				return 128 + int(status.Signal()), CRON_STATUS_HUNG
+			}
+			return CRON_EXITCODE_UNKNOWN, CRON_STATUS_HUNG
+		}
 
-		// 127: command not found
-		if strings.Contains(err.Error(), "executable file not found in $PATH") {
-			return CRON_EXITCODE_EXEC_NOT_FOUND, CRON_STATUS_FAIL
+		// or filled its scratch directory
+		if errors.Is(context.Cause(ctx), errScratchFull) {
+			c.logf("ERROR: scratch directory larger than CRON_SCRATCH_MAX %s, killed the command\n", config.CRON_SCRATCH_MAX)
+			return CRON_EXITCODE_SCRATCH_FULL, CRON_STATUS_FAIL
 		}
 
-		// 126: permission denied
-		if strings.Contains(err.Error(), "permission denied") {
-			return CRON_EXITCODE_PERM_DENIED, CRON_STATUS_FAIL
+		// killed by a signal: ExitStatus() is -1 so report it the way a shell would
+		if signaled {
+			return 128 + int(status.Signal()), CRON_STATUS_FAIL
 		}
 
 		// check if the command failed for any other reason
@@ -359,14 +1134,31 @@ func run_cmd(args []string) (int, int) {
 	return CRON_EXITCODE_SUCCESS, CRON_STATUS_SUCCESS
 }
 
+// waitStatus returns the wait status of a command that exited with an error
+// and whether it was killed by a signal
+func waitStatus(err error) (syscall.WaitStatus, bool) {
+	var exitError *exec.ExitError
+	if !errors.As(err, &exitError) {
+		return 0, false
+	}
+
+	status, ok := exitError.Sys().(syscall.WaitStatus)
+	return status, ok && status.Signaled()
+}
+
 func (c *Cron) setNamespace() {
 	c.Monitor.Namespace = config.CRON_NAMESPACE
 
 	// if no namespace is provided, generate one from the arguments
 	// WARNING: this may cause issues if the arguments are sensitive
+	// secrets matched by the CRON_REDACT_* config are redacted first
 	// TODO: add a flag to disable this feature or require a namespace
+	// in shell mode from the first command in the string, not the shell
+	if c.Monitor.Namespace == "" && c.command != "" {
+		c.Monitor.Namespace = strings.Join(c.redactor.Args(firstCommand(c.command)), "_")
+	}
 	if c.Monitor.Namespace == "" {
-		c.Monitor.Namespace = strings.Join(c.Args, "_")
+		c.Monitor.Namespace = strings.Join(c.redactor.Args(c.Args), "_")
 	}
 
 	// Replace invalid characters in the namespace
@@ -391,7 +1183,7 @@ func (c *Cron) setNamespace() {
 		// the namespace will be different for each run
 		randomID := uuid.New()
 		c.Monitor.Namespace = "randomid_" + randomID.String()
-		fmt.Printf("Invalid namespace: generated a randomid: %s", c.Monitor.Namespace)
+		c.logf("Invalid namespace: generated a randomid: %s\n", c.Monitor.Namespace)
 	}
 }
 
@@ -410,15 +1202,22 @@ func (c *Cron) setMetricPrefix() {
 	}
 }
 
+// metricsMu serializes writing the metrics file, it's rewritten from the
+// background while the command runs
+var metricsMu sync.Mutex
+
 // writeMetrics writes the metrics to a file
 func (c *Cron) writeMetrics() error {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
 	// always write metrics all cron statuses
 	for _, status := range STATUS_CODES {
 		monitor.CronStatus.WithLabelValues(c.Monitor.Namespace, fmt.Sprintf("%d", status.Code), status.Name).Set(boolToInt(c.StatusCode == status.Code))
 	}
 
 	// always write metrics all cron statuses
-	for _, exit := range EXIT_CODES {
+	for _, exit := range c.exitCodeTable() {
 		monitor.CronExit.WithLabelValues(c.Monitor.Namespace, fmt.Sprintf("%d", exit.Code), exit.Name).Set(boolToInt(c.ExitCode == exit.Code))
 	}
 
@@ -428,11 +1227,25 @@ func (c *Cron) writeMetrics() error {
 		return fmt.Errorf("error gathering metrics: %v", err)
 	}
 
-	c.Monitor.Prometheus.WriteMetrics(c.Monitor.Namespace, promMetrics)
+	// the command's own metrics go in the same file
+	promMetrics = append(promMetrics, c.childMetrics...)
+
+	if err := c.Monitor.Prometheus.WriteMetrics(c.Monitor.Namespace, promMetrics); err != nil {
+		return fmt.Errorf("error writing metrics: %v", err)
+	}
 
 	return nil
 }
 
+// logf prints a message from the runner itself, tagged with the run id once
+// there is one so it can be matched to the metrics and report of the run
+func (c *Cron) logf(format string, a ...any) {
+	if c.RunID != "" {
+		format = "[" + c.RunID + "] " + format
+	}
+	fmt.Printf(format, a...)
+}
+
 // boolToInt converts a boolean to an integer aka true -> 1, false -> 0
 func boolToInt(b bool) float64 {
 	if b {
@@ -441,6 +1254,30 @@ func boolToInt(b bool) float64 {
 	return 0
 }
 
+// writeFileAtomic replaces a file so readers never see a partial write
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// CreateTemp creates files as 0600, match what os.Create would have done
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
 func main() {
 	// set umask to 022
 	// this is to ensure that the files created by the script are not world writable
@@ -459,6 +1296,6 @@ func main() {
 	}
 
 	if err := cron.Run(); nil != err {
-		fmt.Printf("ERROR: %v\n", err)
+		cron.logf("ERROR: %v\n", err)
 	}
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"testing"
 	"time"
@@ -10,8 +12,16 @@ import (
 	"github.com/devinodaniel/cron-go/cmd/config"
 )
 
+// setConfig sets a config value for the test, it's restored once it's done
+func setConfig[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	old := *p
+	t.Cleanup(func() { *p = old })
+	*p = v
+}
+
 func TestNew(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"echo", "hello world"}
 	cron, _ := New(args)
@@ -28,7 +38,7 @@ func TestNew(t *testing.T) {
 }
 
 func TestNewNoArgs(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{}
 
@@ -46,7 +56,7 @@ func TestNewNoArgs(t *testing.T) {
 }
 
 func TestRunSimpleSuccess(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"echo", "hello world"}
 	cron, _ := New(args)
@@ -66,7 +76,7 @@ func TestRunSimpleSuccess(t *testing.T) {
 }
 
 func TestRunRubySuccess(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"ruby", "-e", "puts 'hello world'"}
 	cron, _ := New(args)
@@ -86,7 +96,7 @@ func TestRunRubySuccess(t *testing.T) {
 }
 
 func TestRunSimpleFailStatus(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"false"}
 	cron, _ := New(args)
@@ -107,7 +117,7 @@ func TestRunSimpleFailStatus(t *testing.T) {
 }
 
 func TestRunExitCode1(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"test", "-f", "/tmp/does_not_exist"}
 	cron, _ := New(args)
@@ -125,7 +135,7 @@ func TestRunExitCode1(t *testing.T) {
 
 // TestRunExitCode126 tests the exit code when permission is denied
 func TestRunExitCode126(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"/dev/null"}
 	cron, _ := New(args)
@@ -143,7 +153,7 @@ func TestRunExitCode126(t *testing.T) {
 
 // TestRunExitCode127 tests the exit code when the command is not found
 func TestRunExitCode127(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"invalidornonexistentcommand"}
 	cron, _ := New(args)
@@ -162,7 +172,7 @@ func TestRunExitCode127(t *testing.T) {
 // TestRunSigInterrupted tests the exit code when the command is interrupted
 // a typical example is when the user presses Ctrl+C
 func TestRunSigInterrupted(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"sleep", "5"}
 	cron, _ := New(args)
@@ -195,7 +205,7 @@ func TestRunSigInterrupted(t *testing.T) {
 // TestRunSigTerminated tests the exit code when the command is terminated
 // a typical example is when the user runs `kill <pid>`
 func TestRunSigTerminated(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"sleep", "5"}
 	cron, _ := New(args)
@@ -226,10 +236,10 @@ func TestRunSigTerminated(t *testing.T) {
 }
 
 func TestRunTimeout(t *testing.T) {
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_METRICS, false)
 
 	// Set a very short timeout for the test
-	config.CRON_TIMEOUT = 1
+	setConfig(t, &config.CRON_TIMEOUT, 1)
 
 	args := []string{"sleep", "2"}
 	cron, _ := New(args)
@@ -246,8 +256,8 @@ func TestRunTimeout(t *testing.T) {
 }
 
 func TestCronDuration(t *testing.T) {
-	config.CRON_METRICS = false
-	config.CRON_TIMEOUT = 3
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_TIMEOUT, 3)
 
 	args := []string{"sleep", "1"}
 	cron, _ := New(args)
@@ -273,8 +283,8 @@ func TestCronDuration(t *testing.T) {
 }
 
 func TestMetricsNamespace(t *testing.T) {
-	config.CRON_NAMESPACE = "test namespace"
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_NAMESPACE, "test namespace")
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"echo", "hello"}
 	cron, _ := New(args)
@@ -288,8 +298,8 @@ func TestMetricsNamespace(t *testing.T) {
 }
 
 func TestMetricsNamespaceCapsAndDash(t *testing.T) {
-	config.CRON_NAMESPACE = "TEST-nameSPACE"
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_NAMESPACE, "TEST-nameSPACE")
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"echo", "hello"}
 	cron, _ := New(args)
@@ -303,8 +313,8 @@ func TestMetricsNamespaceCapsAndDash(t *testing.T) {
 }
 
 func TestMetricsWithNamespaceSpecialChars(t *testing.T) {
-	config.CRON_NAMESPACE = "TEST-nameSPACE!@$%^&*()-=+"
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_NAMESPACE, "TEST-nameSPACE!@$%^&*()-=+")
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"echo", "hello"}
 	cron, _ := New(args)
@@ -318,8 +328,8 @@ func TestMetricsWithNamespaceSpecialChars(t *testing.T) {
 }
 
 func TestWriteMetricsWithNamespaceSpecialCharsWithSpaces(t *testing.T) {
-	config.CRON_NAMESPACE = "TEST-nameSPACE!@$%^&*()-=+ TEST AGAIN"
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_NAMESPACE, "TEST-nameSPACE!@$%^&*()-=+ TEST AGAIN")
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"echo", "hello"}
 	cron, _ := New(args)
@@ -333,8 +343,8 @@ func TestWriteMetricsWithNamespaceSpecialCharsWithSpaces(t *testing.T) {
 }
 
 func TestWriteMetricsWithNamespaceWithFilepath(t *testing.T) {
-	config.CRON_NAMESPACE = "TEST-nameSPACE!@$%^&*()-=+ TEST AGAIN"
-	config.CRON_METRICS = false
+	setConfig(t, &config.CRON_NAMESPACE, "TEST-nameSPACE!@$%^&*()-=+ TEST AGAIN")
+	setConfig(t, &config.CRON_METRICS, false)
 
 	args := []string{"cat", "/tmp/does_not_exist/this/should/not/exist.txt"}
 	cron, _ := New(args)
@@ -354,3 +364,71 @@ func TestWriteMetricsWithNamespaceWithFilepath(t *testing.T) {
 		t.Errorf("Expected exit code %d, got %d", 1, cron.ExitCode)
 	}
 }
+
+func TestRunID(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_NAMESPACE, "")
+
+	first, _ := New([]string{"true"})
+	first.Run()
+
+	second, _ := New([]string{"true"})
+	second.Run()
+
+	if first.RunID == "" || first.RunID == second.RunID {
+		t.Errorf("Expected a unique run id per run, got %s and %s", first.RunID, second.RunID)
+	}
+}
+
+func TestRunChildEnv(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_NAMESPACE, "")
+	setConfig(t, &config.CRON_TIMEOUT, 60)
+
+	out := filepath.Join(t.TempDir(), "env")
+	args := []string{"sh", "-c", `echo "$CRON_RUN_ID $CRON_NAMESPACE $CRON_START_TIME $CRON_DEADLINE $CRON_ATTEMPT" > ` + out}
+	cron, _ := New(args)
+	cron.Run()
+
+	data, _ := os.ReadFile(out)
+	expected := fmt.Sprintf("%s %s %d %d 1\n", cron.RunID, cron.Monitor.Namespace, cron.StartTime.Unix(), cron.cmdStart.Unix()+60)
+	if string(data) != expected {
+		t.Errorf("Expected child env %q, got %q", expected, string(data))
+	}
+}
+
+// TestRunKilledBySignal tests that a command killed by a signal gets 128 + the signal number
+func TestRunKilledBySignal(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_TIMEOUT, 60)
+
+	args := []string{"sh", "-c", "kill -KILL $$"}
+	cron, _ := New(args)
+
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_FAIL, cron.StatusCode)
+	}
+
+	if cron.ExitCode != CRON_EXITCODE_SIG_KILL || cron.GetExitCodeName() != "SIG_KILL" {
+		t.Errorf("Expected exit code %d (SIG_KILL), got %d (%s)", CRON_EXITCODE_SIG_KILL, cron.ExitCode, cron.GetExitCodeName())
+	}
+
+	if cron.Signal != "SIGKILL" {
+		t.Errorf("Expected signal %s, got %s", "SIGKILL", cron.Signal)
+	}
+}
+
+func TestRunKilledBySegfault(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+
+	args := []string{"sh", "-c", "kill -SEGV $$"}
+	cron, _ := New(args)
+
+	cron.Run()
+
+	if cron.ExitCode != CRON_EXITCODE_SIG_SEGV || cron.Signal != "SIGSEGV" {
+		t.Errorf("Expected exit code %d (SIGSEGV), got %d (%s)", CRON_EXITCODE_SIG_SEGV, cron.ExitCode, cron.Signal)
+	}
+}
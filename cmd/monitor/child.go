@@ -0,0 +1,97 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
+
+	io_prometheus_client "github.com/prometheus/client_model/go"
+)
+
+// CHILD_METRICS_PREFIX is put in front of every metric the command publishes
+// so they're grouped together and can't be mistaken for the runner's own
+const CHILD_METRICS_PREFIX = "cron_job_"
+
+var validMetricName = regexp.MustCompile("^[a-zA-Z_:][a-zA-Z0-9_:]*$")
+
+// ParseChildMetrics parses the Prometheus text format samples the command
+// wrote, prefixes and namespaces them. Metrics named like the runner's own
+// (cron_*) and samples with a timestamp are rejected and returned as errors
+// while the rest are kept.
+func ParseChildMetrics(in io.Reader, namespace string) ([]*io_prometheus_client.MetricFamily, []error) {
+	var parser expfmt.TextParser
+
+	parsed, err := parser.TextToMetricFamilies(in)
+	if err != nil {
+		return nil, []error{fmt.Errorf("invalid metrics: %v", err)}
+	}
+
+	var families []*io_prometheus_client.MetricFamily
+	var errs []error
+
+	for name, family := range parsed {
+		if !validMetricName.MatchString(name) {
+			errs = append(errs, fmt.Errorf("rejected metric %s: invalid name", name))
+			continue
+		}
+
+		// cron_ belongs to the runner, ie: a command can't fake its own cron_status
+		prefixed := name
+		if !strings.HasPrefix(name, CHILD_METRICS_PREFIX) {
+			if strings.HasPrefix(name, "cron_") {
+				errs = append(errs, fmt.Errorf("rejected metric %s: collides with the runner's metrics", name))
+				continue
+			}
+			prefixed = CHILD_METRICS_PREFIX + name
+		}
+
+		// the textfile collector refuses the whole file if a single sample has one
+		var metrics []*io_prometheus_client.Metric
+		for _, metric := range family.Metric {
+			if metric.TimestampMs != nil {
+				errs = append(errs, fmt.Errorf("rejected sample of %s: timestamps aren't supported by the textfile collector", name))
+				continue
+			}
+			metric.Label = withNamespace(metric.Label, namespace)
+			metrics = append(metrics, metric)
+		}
+		if len(metrics) == 0 {
+			continue
+		}
+
+		family.Name = proto.String(prefixed)
+		family.Metric = metrics
+		families = append(families, family)
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+
+	return families, errs
+}
+
+// withNamespace sets the namespace label, replacing one the command may have set
+func withNamespace(labels []*io_prometheus_client.LabelPair, namespace string) []*io_prometheus_client.LabelPair {
+	namespaced := []*io_prometheus_client.LabelPair{{
+		Name:  proto.String("namespace"),
+		Value: proto.String(namespace),
+	}}
+
+	for _, label := range labels {
+		if label.GetName() != "namespace" {
+			namespaced = append(namespaced, label)
+		}
+	}
+
+	sort.Slice(namespaced, func(i, j int) bool {
+		return namespaced[i].GetName() < namespaced[j].GetName()
+	})
+
+	return namespaced
+}
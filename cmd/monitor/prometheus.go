@@ -3,6 +3,7 @@ package monitor
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/devinodaniel/cron-go/cmd/config"
 
@@ -91,25 +92,288 @@ var (
 			Help: "Exit of cronjob last run",
 		},
 		[]string{"namespace", "code", "exit"})
+
+	CronSignal = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_signal",
+			Help: "Signal that killed the cronjob command last run",
+		},
+		[]string{"namespace", "signal"})
+
+	CronCoreDumped = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_core_dumped",
+			Help: "Whether the cronjob command dumped core last run",
+		},
+		[]string{"namespace"})
+
+	CronLaunchFailure = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_launch_failure",
+			Help: "Reason the cronjob command could not be started last run",
+		},
+		[]string{"namespace", "reason"})
+
+	CronOutputLines = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_output_lines",
+			Help: "Lines of cronjob output last run matching a pattern",
+		},
+		[]string{"namespace", "stream", "pattern"})
+
+	CronOutputTotalLines = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_output_total_lines",
+			Help: "Lines of cronjob output last run",
+		},
+		[]string{"namespace", "stream"})
+
+	CronOutputBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_output_bytes",
+			Help: "Bytes of cronjob output last run",
+		},
+		[]string{"namespace", "stream"})
+
+	CronProgressRatio = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_progress_ratio",
+			Help: "Progress the cronjob reported over the notification socket, 0 to 1",
+		},
+		[]string{"namespace"})
+
+	CronLastHeartbeatSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_last_heartbeat_seconds",
+			Help: "Time of the last watchdog heartbeat the cronjob sent",
+		},
+		[]string{"namespace"})
+
+	CronElapsedSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_elapsed_seconds",
+			Help: "Time the cronjob has been running for",
+		},
+		[]string{"namespace"})
+
+	CronLastUpdateTimeSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_last_update_time_seconds",
+			Help: "Time the runner last wrote the metrics (epoch)",
+		},
+		[]string{"namespace"})
+
+	CronCPUSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_cpu_seconds",
+			Help: "CPU time used by the cronjob and its child processes",
+		},
+		[]string{"namespace"})
+
+	CronMemoryRSSBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_memory_rss_bytes",
+			Help: "Resident memory of the cronjob and its child processes while running",
+		},
+		[]string{"namespace"})
+
+	CronCPUUserSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_cpu_user_seconds",
+			Help: "User CPU time of cronjob last run",
+		},
+		[]string{"namespace"})
+
+	CronCPUSystemSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_cpu_system_seconds",
+			Help: "System CPU time of cronjob last run",
+		},
+		[]string{"namespace"})
+
+	CronMaxRSSBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_max_rss_bytes",
+			Help: "Peak resident memory of cronjob last run",
+		},
+		[]string{"namespace"})
+
+	CronBlockIOOperations = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_block_io_operations",
+			Help: "Block I/O operations of cronjob last run",
+		},
+		[]string{"namespace", "direction"})
+
+	CronContextSwitches = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_context_switches",
+			Help: "Context switches of cronjob last run",
+		},
+		[]string{"namespace", "type"})
+
+	CronPageFaults = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_page_faults",
+			Help: "Page faults of cronjob last run",
+		},
+		[]string{"namespace", "type"})
+
+	CronCPUPercent = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_cpu_percent",
+			Help: "CPU usage of the cronjob and its child processes while running, 100 is one core",
+		},
+		[]string{"namespace"})
+
+	CronProcesses = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_processes",
+			Help: "Processes of the cronjob while running",
+		},
+		[]string{"namespace"})
+
+	CronThreads = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_threads",
+			Help: "Threads of the cronjob and its child processes while running",
+		},
+		[]string{"namespace"})
+
+	CronOpenFDs = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_open_fds",
+			Help: "Open file descriptors of the cronjob and its child processes while running",
+		},
+		[]string{"namespace"})
+
+	CronIOBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_io_bytes",
+			Help: "Bytes the cronjob and its child processes read from or wrote to storage",
+		},
+		[]string{"namespace", "direction"})
+
+	CronPeakCPUPercent = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_peak_cpu_percent",
+			Help: "Peak CPU usage of cronjob last run, 100 is one core",
+		},
+		[]string{"namespace"})
+
+	CronPeakMemoryRSSBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_peak_memory_rss_bytes",
+			Help: "Peak resident memory of cronjob last run, all processes together",
+		},
+		[]string{"namespace"})
+
+	CronPeakProcesses = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_peak_processes",
+			Help: "Peak number of processes of cronjob last run",
+		},
+		[]string{"namespace"})
+
+	CronPeakThreads = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_peak_threads",
+			Help: "Peak number of threads of cronjob last run",
+		},
+		[]string{"namespace"})
+
+	CronPeakOpenFDs = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_peak_open_fds",
+			Help: "Peak number of open file descriptors of cronjob last run",
+		},
+		[]string{"namespace"})
+
+	CronCgroupCPUSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_cgroup_cpu_seconds",
+			Help: "CPU time of everything cronjob last run started, from its cgroup",
+		},
+		[]string{"namespace", "mode"})
+
+	CronCgroupMemoryPeakBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_cgroup_memory_peak_bytes",
+			Help: "Peak memory of everything cronjob last run started, from its cgroup",
+		},
+		[]string{"namespace"})
+
+	CronOOMKills = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_oom_kills",
+			Help: "Processes of cronjob last run killed by the OOM killer",
+		},
+		[]string{"namespace"})
+
+	CronPreconditionFailed = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_precondition_failed",
+			Help: "Precondition that kept the cronjob command from running last run",
+		},
+		[]string{"namespace", "reason"})
+
+	CronScratchBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_scratch_bytes",
+			Help: "Size of the scratch directory of cronjob last run (bytes)",
+		},
+		[]string{"namespace"})
+
+	CronHookStatusCode = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_hook_status_code",
+			Help: "Status code of each hook of cronjob last run",
+		},
+		[]string{"namespace", "hook"})
+
+	CronHookExitCode = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_hook_exit_code",
+			Help: "Exit code of each hook of cronjob last run",
+		},
+		[]string{"namespace", "hook"})
+
+	CronHookDurationMilliseconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cron_hook_duration_milliseconds",
+			Help: "Duration of each hook of cronjob last run (milliseconds)",
+		},
+		[]string{"namespace", "hook"})
 )
 
 func (p *Prometheus) WriteMetrics(namespace string, metrics []*io_prometheus_client.MetricFamily) error {
 	// set write filepath
 	metricsFile := fmt.Sprintf(config.CRON_METRICS_DIR+"/cron_%s_metrics.prom", namespace)
 
-	// Create or open the file
-	file, err := os.Create(metricsFile)
+	// write to a temp file and rename it over the old one so the textfile
+	// collector never scrapes a half written file while the cron is running
+	file, err := os.CreateTemp(filepath.Dir(metricsFile), "."+filepath.Base(metricsFile)+"-*")
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer file.Close()
+	defer os.Remove(file.Name())
 
 	// Encode metrics in Prometheus text format
 	encoder := expfmt.NewEncoder(file, expfmt.NewFormat(expfmt.TypeTextPlain))
 	for _, metricFamily := range metrics {
 		if err := encoder.Encode(metricFamily); err != nil {
-			panic(err)
+			file.Close()
+			return err
 		}
 	}
-	return nil
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// CreateTemp creates files as 0600, the node exporter may run as another user
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), metricsFile)
 }
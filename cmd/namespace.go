@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// NAMESPACE COLLISION POLICIES
+const (
+	CRON_COLLISION_WARN   = "warn"   // warn and let the new command take over the namespace
+	CRON_COLLISION_FAIL   = "fail"   // refuse to run the new command
+	CRON_COLLISION_SUFFIX = "suffix" // move the new command to <namespace>_<fingerprint>
+)
+
+// checkNamespaceCollision validates CRON_NAMESPACE_COLLISION
+func checkNamespaceCollision() error {
+	switch config.CRON_NAMESPACE_COLLISION {
+	case CRON_COLLISION_WARN, CRON_COLLISION_FAIL, CRON_COLLISION_SUFFIX:
+		return nil
+	}
+	return fmt.Errorf("invalid CRON_NAMESPACE_COLLISION: %s, must be %s, %s or %s", config.CRON_NAMESPACE_COLLISION, CRON_COLLISION_WARN, CRON_COLLISION_FAIL, CRON_COLLISION_SUFFIX)
+}
+
+// NamespaceEntry is a single record in the namespace registry
+type NamespaceEntry struct {
+	Fingerprint string    `json:"fingerprint"`
+	Command     string    `json:"command"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+}
+
+// fingerprint returns a stable hash of the command and its arguments
+func fingerprint(args []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(args, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// registryPath returns the path of the namespace registry in the state dir
+func registryPath() string {
+	return filepath.Join(config.CRON_STATE_DIR, "namespaces.json")
+}
+
+// readRegistry reads the namespace registry, a missing registry is empty
+func readRegistry() (map[string]*NamespaceEntry, error) {
+	registry := make(map[string]*NamespaceEntry)
+
+	data, err := os.ReadFile(registryPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", registryPath(), err)
+	}
+
+	return registry, nil
+}
+
+// writeRegistry atomically replaces the namespace registry
+func writeRegistry(registry map[string]*NamespaceEntry) error {
+	data, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(registryPath(), data)
+}
+
+// lockRegistry takes an exclusive lock on the registry so that crons starting
+// at the same minute don't clobber each other's entries
+func lockRegistry() (*os.File, error) {
+	if err := os.MkdirAll(config.CRON_STATE_DIR, 0755); err != nil {
+		return nil, err
+	}
+
+	lock, err := os.OpenFile(filepath.Join(config.CRON_STATE_DIR, "namespaces.lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// claimNamespace records the namespace in the registry and checks that no other
+// command already owns it. Two different commands can sanitize to the same
+// namespace (ie: `backup.sh db1` and `backup.sh db-1`) and would silently
+// overwrite each other's metrics file.
+// only a collision under the fail policy returns an error, problems with the
+// registry itself are printed and ignored so they never block a cron
+func (c *Cron) claimNamespace() error {
+	// a random namespace is different on every run, it can't collide and would
+	// only grow the registry
+	if config.CRON_STATE_DIR == "" || strings.HasPrefix(c.Monitor.Namespace, "randomid_") {
+		return nil
+	}
+
+	lock, err := lockRegistry()
+	if err != nil {
+		c.logf("WARNING: unable to lock namespace registry: %v\n", err)
+		return nil
+	}
+	defer lock.Close()
+
+	registry, err := readRegistry()
+	if err != nil {
+		c.logf("WARNING: unable to read namespace registry: %v\n", err)
+		return nil
+	}
+
+	now := time.Now()
+	args := c.redactor.Args(c.Args)
+	fp := fingerprint(args)
+	command := strings.Join(args, " ")
+
+	entry, exists := registry[c.Monitor.Namespace]
+	if exists && entry.Fingerprint != fp {
+		switch config.CRON_NAMESPACE_COLLISION {
+		case CRON_COLLISION_FAIL:
+			return fmt.Errorf("namespace %s is already claimed by another command: %s", c.Monitor.Namespace, entry.Command)
+		case CRON_COLLISION_SUFFIX:
+			c.Monitor.Namespace = c.Monitor.Namespace + "_" + fp[:8]
+			c.logf("WARNING: namespace collision with command '%s': using namespace %s\n", entry.Command, c.Monitor.Namespace)
+			entry, exists = registry[c.Monitor.Namespace]
+		default:
+			c.logf("WARNING: namespace %s was claimed by another command '%s': its metrics will be overwritten\n", c.Monitor.Namespace, entry.Command)
+			exists = false
+		}
+	}
+
+	if !exists {
+		entry = &NamespaceEntry{FirstSeen: now}
+		registry[c.Monitor.Namespace] = entry
+	}
+	entry.Fingerprint = fp
+	entry.Command = command
+	entry.LastSeen = now
+
+	if err := writeRegistry(registry); err != nil {
+		c.logf("WARNING: unable to write namespace registry: %v\n", err)
+	}
+
+	return nil
+}
+
+// listNamespaces prints the namespace registry
+func listNamespaces() error {
+	if config.CRON_STATE_DIR == "" {
+		return errors.New("the namespace registry is disabled, set CRON_STATE_DIR to enable it")
+	}
+
+	registry, err := readRegistry()
+	if err != nil {
+		return err
+	}
+
+	if len(registry) == 0 {
+		fmt.Printf("No namespaces registered in %s\n", registryPath())
+		return nil
+	}
+
+	namespaces := make([]string, 0, len(registry))
+	for namespace := range registry {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tFINGERPRINT\tLAST SEEN\tCOMMAND")
+	for _, namespace := range namespaces {
+		entry := registry[namespace]
+		fmt.Fprintf(w, "%s\t%.12s\t%s\t%s\n", namespace, entry.Fingerprint, entry.LastSeen.Format(time.RFC3339), entry.Command)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func claim(t *testing.T, namespace string, args ...string) (*Cron, error) {
+	cron, _ := New(args)
+	cron.Monitor.Namespace = namespace
+	return cron, cron.claimNamespace()
+}
+
+func TestClaimNamespaceSameCommand(t *testing.T) {
+	setConfig(t, &config.CRON_STATE_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE_COLLISION, CRON_COLLISION_FAIL)
+
+	for i := 0; i < 2; i++ {
+		if _, err := claim(t, "backup_sh_db1", "backup.sh", "db1"); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}
+
+	registry, _ := readRegistry()
+	if len(registry) != 1 {
+		t.Errorf("Expected 1 registered namespace, got %d", len(registry))
+	}
+}
+
+func TestClaimNamespaceCollisionFail(t *testing.T) {
+	setConfig(t, &config.CRON_STATE_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE_COLLISION, CRON_COLLISION_FAIL)
+
+	claim(t, "backup_sh_db1", "backup.sh", "db1")
+
+	if _, err := claim(t, "backup_sh_db1", "backup.sh", "db-1"); err == nil {
+		t.Errorf("Expected a namespace collision error, got nil")
+	}
+
+	registry, _ := readRegistry()
+	if registry["backup_sh_db1"].Command != "backup.sh db1" {
+		t.Errorf("Expected namespace to still belong to %s, got %s", "backup.sh db1", registry["backup_sh_db1"].Command)
+	}
+}
+
+func TestClaimNamespaceCollisionSuffix(t *testing.T) {
+	setConfig(t, &config.CRON_STATE_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE_COLLISION, CRON_COLLISION_SUFFIX)
+
+	claim(t, "backup_sh_db1", "backup.sh", "db1")
+
+	cron, err := claim(t, "backup_sh_db1", "backup.sh", "db-1")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	expected := "backup_sh_db1_" + fingerprint([]string{"backup.sh", "db-1"})[:8]
+	if cron.Monitor.Namespace != expected {
+		t.Errorf("Expected namespace to be %s, got %s", expected, cron.Monitor.Namespace)
+	}
+
+	// the disambiguated namespace is stable across runs
+	cron, _ = claim(t, "backup_sh_db1", "backup.sh", "db-1")
+	if cron.Monitor.Namespace != expected {
+		t.Errorf("Expected namespace to be %s, got %s", expected, cron.Monitor.Namespace)
+	}
+}
+
+func TestClaimNamespaceCollisionWarn(t *testing.T) {
+	setConfig(t, &config.CRON_STATE_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE_COLLISION, CRON_COLLISION_WARN)
+
+	claim(t, "backup_sh_db1", "backup.sh", "db1")
+
+	cron, err := claim(t, "backup_sh_db1", "backup.sh", "db-1")
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if cron.Monitor.Namespace != "backup_sh_db1" {
+		t.Errorf("Expected namespace to be %s, got %s", "backup_sh_db1", cron.Monitor.Namespace)
+	}
+
+	registry, _ := readRegistry()
+	if registry["backup_sh_db1"].Command != "backup.sh db-1" {
+		t.Errorf("Expected namespace to belong to %s, got %s", "backup.sh db-1", registry["backup_sh_db1"].Command)
+	}
+}
+
+func TestRunNamespaceCollisionFail(t *testing.T) {
+	setConfig(t, &config.CRON_STATE_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE_COLLISION, CRON_COLLISION_FAIL)
+	setConfig(t, &config.CRON_NAMESPACE, "shared")
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+
+	first, _ := New([]string{"true"})
+	if err := first.Run(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	second, _ := New([]string{"false"})
+	if err := second.Run(); err == nil {
+		t.Errorf("Expected a namespace collision error, got nil")
+	}
+
+	if second.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_FAIL, second.StatusCode)
+	}
+}
+
+func TestClaimNamespaceRandomID(t *testing.T) {
+	setConfig(t, &config.CRON_STATE_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE_COLLISION, CRON_COLLISION_FAIL)
+
+	claim(t, "randomid_1234", "backup.sh", "db1")
+
+	registry, _ := readRegistry()
+	if len(registry) != 0 {
+		t.Errorf("Expected random namespaces not to be registered, got %v", registry)
+	}
+}
+
+func TestNewInvalidNamespaceCollision(t *testing.T) {
+	setConfig(t, &config.CRON_STATE_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE_COLLISION, "ignore")
+
+	if _, err := New([]string{"true"}); err == nil {
+		t.Errorf("Expected an invalid CRON_NAMESPACE_COLLISION error, got nil")
+	}
+}
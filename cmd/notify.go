@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+	"github.com/devinodaniel/cron-go/cmd/monitor"
+	"golang.org/x/sys/unix"
+)
+
+// errWatchdog is the cause of the cancellation when the watchdog expires
+var errWatchdog = errors.New("watchdog expired")
+
+// how often a notification may rewrite the metrics file, the last
+// notification always makes it into the metrics written at finish
+const notifyWriteInterval = time.Second
+
+// NotifyState is what the command reported over the notification socket
+type NotifyState struct {
+	StatusText    string    `json:"statusText,omitempty"` // STATUS=
+	Progress      float64   `json:"progress"`             // PROGRESS= as a ratio 0-1
+	LastHeartbeat time.Time `json:"lastHeartbeat"`        // last WATCHDOG=1
+}
+
+// notifier listens on a sd_notify compatible datagram socket so that a long
+// running command can tell the runner it's still making progress
+type notifier struct {
+	dir  string
+	conn *net.UnixConn
+
+	mu        sync.Mutex
+	state     NotifyState
+	heartbeat time.Time // last WATCHDOG=1, or when the command started
+	lastWrite time.Time
+}
+
+// startNotify opens the notification socket, nil if disabled
+func (c *Cron) startNotify() (*notifier, error) {
+	if !config.CRON_NOTIFY && config.CRON_WATCHDOG <= 0 {
+		return nil, nil
+	}
+
+	// sockets paths are limited to ~100 bytes so keep it short
+	dir, err := os.MkdirTemp("", "cron-runner-")
+	if err != nil {
+		return nil, err
+	}
+
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "notify"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	for _, path := range []string{dir, addr.Name} {
+		if err := c.runAs.chown(path); err != nil {
+			conn.Close()
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	return &notifier{
+		dir:       dir,
+		conn:      conn,
+		heartbeat: time.Now(),
+	}, nil
+}
+
+// socket returns the path of the notification socket
+func (n *notifier) socket() string {
+	return n.conn.LocalAddr().String()
+}
+
+// serve reads notifications until stop is called
+func (n *notifier) serve(c *Cron) {
+	buf := make([]byte, 4096)
+
+	for {
+		size, err := n.conn.Read(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			n.drain(c.redactor, buf)
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		if n.handle(string(buf[:size]), c.redactor) {
+			c.writeNotifyMetrics()
+		}
+	}
+}
+
+// handle applies a single datagram of newline separated KEY=VALUE assignments
+// and returns whether the metrics should be rewritten
+func (n *notifier) handle(message string, redactor *Redactor) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, line := range strings.Split(message, "\n") {
+		key, value, _ := strings.Cut(line, "=")
+
+		switch key {
+		case "STATUS":
+			n.state.StatusText = redactor.String(value)
+		case "PROGRESS":
+			// percent, like systemd-style progress reporting
+			if percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err == nil {
+				n.state.Progress = min(max(percent, 0), 100) / 100
+			}
+		case "WATCHDOG":
+			if value == "1" {
+				n.heartbeat = time.Now()
+				n.state.LastHeartbeat = n.heartbeat
+			}
+		}
+	}
+
+	if time.Since(n.lastWrite) < notifyWriteInterval {
+		return false
+	}
+	n.lastWrite = time.Now()
+
+	return true
+}
+
+// watchdog calls expire once no heartbeat was received for CRON_WATCHDOG seconds
+func (n *notifier) watchdog(done <-chan struct{}, expire func()) {
+	interval := time.Duration(config.CRON_WATCHDOG) * time.Second
+
+	ticker := time.NewTicker(min(interval/4, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			n.mu.Lock()
+			expired := time.Since(n.heartbeat) > interval
+			n.mu.Unlock()
+
+			if expired {
+				expire()
+				return
+			}
+		}
+	}
+}
+
+// snapshot returns what the command reported so far
+func (n *notifier) snapshot() NotifyState {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.state
+}
+
+// drain handles the notifications still queued on the socket without waiting
+// for more, the command may send its last ones right before exiting
+func (n *notifier) drain(redactor *Redactor, buf []byte) {
+	raw, err := n.conn.SyscallConn()
+	if err != nil {
+		return
+	}
+
+	n.conn.SetReadDeadline(time.Time{})
+	raw.Read(func(fd uintptr) bool {
+		for {
+			size, _, err := unix.Recvfrom(int(fd), buf, unix.MSG_DONTWAIT)
+			if err != nil {
+				return true
+			}
+			n.handle(string(buf[:size]), redactor)
+		}
+	})
+}
+
+// stop makes serve return once the queued notifications are handled
+func (n *notifier) stop() {
+	n.conn.SetReadDeadline(time.Now())
+}
+
+// close stops listening and removes the socket
+func (n *notifier) close() {
+	n.conn.Close()
+	os.RemoveAll(n.dir)
+}
+
+// childEnv returns the env vars that point the command at the socket
+func (n *notifier) childEnv() []string {
+	env := []string{
+		"NOTIFY_SOCKET=" + n.socket(),
+		"CRON_NOTIFY_SOCKET=" + n.socket(),
+	}
+
+	// sd_watchdog_enabled() compatible
+	if config.CRON_WATCHDOG > 0 {
+		env = append(env, fmt.Sprintf("WATCHDOG_USEC=%d", int64(config.CRON_WATCHDOG)*int64(time.Second/time.Microsecond)))
+	}
+
+	return env
+}
+
+// setNotifyMetrics sets the progress and heartbeat metrics
+func (c *Cron) setNotifyMetrics() {
+	if c.notifier == nil {
+		return
+	}
+
+	state := c.notifier.snapshot()
+	monitor.CronProgressRatio.WithLabelValues(c.Monitor.Namespace).Set(state.Progress)
+	if !state.LastHeartbeat.IsZero() {
+		monitor.CronLastHeartbeatSeconds.WithLabelValues(c.Monitor.Namespace).Set(float64(state.LastHeartbeat.Unix()))
+	}
+}
+
+// writeNotifyMetrics rewrites the metrics file with the latest progress
+func (c *Cron) writeNotifyMetrics() {
+	if !config.CRON_METRICS {
+		return
+	}
+
+	c.setNotifyMetrics()
+	if err := c.writeMetrics(); err != nil {
+		c.logf("WARNING: %v\n", err)
+	}
+}
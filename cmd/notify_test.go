@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// notifyCommand returns a command that sends the messages to the notification
+// socket, one datagram each, by running this test binary as the child
+// "sleep" pauses between messages instead of sending one
+func notifyCommand(t *testing.T, messages ...string) []string {
+	t.Setenv("NOTIFY_TEST_MESSAGES", strings.Join(messages, ";"))
+	return []string{os.Args[0], "-test.run=^TestNotifyHelper$"}
+}
+
+// TestNotifyHelper isn't a real test, it's the child started by notifyCommand
+func TestNotifyHelper(t *testing.T) {
+	messages, ok := os.LookupEnv("NOTIFY_TEST_MESSAGES")
+	if !ok || os.Getenv("NOTIFY_SOCKET") == "" {
+		t.Skip("only runs as a child of the runner")
+	}
+
+	conn, err := net.Dial("unixgram", os.Getenv("NOTIFY_SOCKET"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, message := range strings.Split(messages, ";") {
+		if message == "sleep" {
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+		if _, err := conn.Write([]byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNotifyProgress(t *testing.T) {
+	t.Setenv("NOTIFY_TEST_SECRET", "hunter2")
+	setConfig(t, &config.CRON_REDACT_ENV, []string{"NOTIFY_TEST_SECRET"})
+	setConfig(t, &config.CRON_NOTIFY, true)
+	setConfig(t, &config.CRON_WATCHDOG, 0)
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE, "notify_progress")
+
+	cron, _ := New(notifyCommand(t, "STATUS=uploading with hunter2\nPROGRESS=40", "WATCHDOG=1"))
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Fatalf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+	if cron.Notify == nil || cron.Notify.Progress != 0.4 || cron.Notify.LastHeartbeat.IsZero() {
+		t.Fatalf("Expected progress 0.4 and a heartbeat, got %+v", cron.Notify)
+	}
+	if cron.Notify.StatusText != "uploading with "+REDACTED {
+		t.Errorf("Expected the status text to be redacted, got %s", cron.Notify.StatusText)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(config.CRON_METRICS_DIR, "cron_notify_progress_metrics.prom"))
+	for _, metric := range []string{
+		`cron_progress_ratio{namespace="notify_progress"} 0.4`,
+		`cron_last_heartbeat_seconds{namespace="notify_progress"}`,
+	} {
+		if !strings.Contains(string(data), metric) {
+			t.Errorf("Expected metrics file to contain %s", metric)
+		}
+	}
+}
+
+func TestNotifyWatchdog(t *testing.T) {
+	setConfig(t, &config.CRON_NOTIFY, true)
+	setConfig(t, &config.CRON_WATCHDOG, 1)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"sleep", "10"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_WATCHDOG {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_WATCHDOG, cron.StatusCode)
+	}
+	if cron.ExitCode != CRON_EXITCODE_SIG_KILL {
+		t.Errorf("Expected exit code %d, got %d", CRON_EXITCODE_SIG_KILL, cron.ExitCode)
+	}
+	if cron.Duration > 5*time.Second {
+		t.Errorf("Expected the watchdog to kill the command after ~1s, took %v", cron.Duration)
+	}
+}
+
+func TestNotifyWatchdogHeartbeats(t *testing.T) {
+	setConfig(t, &config.CRON_NOTIFY, true)
+	setConfig(t, &config.CRON_WATCHDOG, 2)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	// 2.5s in total but never more than 0.5s without a heartbeat once it's up
+	cron, _ := New(notifyCommand(t, "WATCHDOG=1", "sleep", "WATCHDOG=1", "sleep", "WATCHDOG=1", "sleep", "WATCHDOG=1", "sleep", "WATCHDOG=1", "sleep"))
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+}
+
+func TestNotifyHandle(t *testing.T) {
+	n := &notifier{}
+
+	for _, tc := range []struct {
+		message  string
+		progress float64
+	}{
+		{"PROGRESS=25", 0.25},
+		{"PROGRESS=50%", 0.5},
+		{"PROGRESS=250", 1},
+		{"PROGRESS=-1", 0},
+		{"PROGRESS=75", 0.75},
+		{"PROGRESS=abc", 0.75}, // ignored, keeps the previous value
+	} {
+		n.handle(tc.message, nil)
+		if got := n.snapshot().Progress; got != tc.progress {
+			t.Errorf("Expected progress %v for %s, got %v", tc.progress, tc.message, got)
+		}
+	}
+
+	// anything else is ignored, like sd_notify does
+	n.handle("READY=1\nMAINPID=1\nWATCHDOG=0", nil)
+	if !n.snapshot().LastHeartbeat.IsZero() {
+		t.Errorf("Expected no heartbeat, got %v", n.snapshot().LastHeartbeat)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// IONICE CLASSES
+// the ioprio classes of ioprio_set(2)
+const (
+	CRON_IONICE_REALTIME    = "realtime"
+	CRON_IONICE_BEST_EFFORT = "best-effort"
+	CRON_IONICE_IDLE        = "idle"
+)
+
+var ioniceClasses = map[string]int{
+	CRON_IONICE_REALTIME:    1,
+	CRON_IONICE_BEST_EFFORT: 2,
+	CRON_IONICE_IDLE:        3,
+}
+
+// errProcessOptions is returned when the command can't be started with the
+// options it was asked to run with
+var errProcessOptions = errors.New("error applying the process options")
+
+// rlimit is a soft and hard limit, math.MaxUint64 is unlimited
+type rlimit struct {
+	name     string // nofile, nproc, core or as
+	resource int
+	soft     uint64
+	hard     uint64
+}
+
+// processOptions is how the command is spawned, nil fields are inherited from the runner
+type processOptions struct {
+	dir      string
+	umask    *int
+	nice     *int
+	ionice   *[2]int // class and level
+	affinity []int
+	rlimits  []rlimit
+}
+
+// newProcessOptions parses the CRON_* process options
+func newProcessOptions() (*processOptions, error) {
+	opts := &processOptions{dir: config.CRON_CHDIR}
+
+	if config.CRON_UMASK != "" {
+		umask, err := strconv.ParseUint(config.CRON_UMASK, 8, 32)
+		if err != nil || umask > 0777 {
+			return nil, fmt.Errorf("invalid CRON_UMASK: %s", config.CRON_UMASK)
+		}
+		opts.umask = ptr(int(umask))
+	}
+
+	if config.CRON_NICE != "" {
+		nice, err := strconv.Atoi(config.CRON_NICE)
+		if err != nil || nice < -20 || nice > 19 {
+			return nil, fmt.Errorf("invalid CRON_NICE: %s", config.CRON_NICE)
+		}
+		opts.nice = ptr(nice)
+	}
+
+	if config.CRON_IONICE != "" {
+		ionice, err := parseIonice(config.CRON_IONICE)
+		if err != nil {
+			return nil, err
+		}
+		opts.ionice = &ionice
+	}
+
+	if config.CRON_CPU_AFFINITY != "" {
+		affinity, err := parseCPUList(config.CRON_CPU_AFFINITY)
+		if err != nil {
+			return nil, err
+		}
+		opts.affinity = affinity
+	}
+
+	for _, limit := range []struct {
+		name, value string
+	}{
+		{"nofile", config.CRON_RLIMIT_NOFILE},
+		{"nproc", config.CRON_RLIMIT_NPROC},
+		{"core", config.CRON_RLIMIT_CORE},
+		{"as", config.CRON_RLIMIT_AS},
+	} {
+		if limit.value == "" {
+			continue
+		}
+		rlimit, err := parseRlimit(limit.name, limit.value)
+		if err != nil {
+			return nil, err
+		}
+		opts.rlimits = append(opts.rlimits, rlimit)
+	}
+
+	return opts, nil
+}
+
+// parseIonice parses <class>[:<level>], ie: best-effort:7
+func parseIonice(value string) ([2]int, error) {
+	name, levelStr, hasLevel := strings.Cut(value, ":")
+
+	class, ok := ioniceClasses[name]
+	if !ok {
+		return [2]int{}, fmt.Errorf("invalid CRON_IONICE class: %s", name)
+	}
+
+	// the kernel's default level for best-effort and realtime
+	level := 4
+	if class == ioniceClasses[CRON_IONICE_IDLE] {
+		level = 0
+	}
+	if hasLevel {
+		var err error
+		level, err = strconv.Atoi(levelStr)
+		if err != nil || level < 0 || level > 7 {
+			return [2]int{}, fmt.Errorf("invalid CRON_IONICE level: %s", levelStr)
+		}
+	}
+
+	return [2]int{class, level}, nil
+}
+
+// parseCPUList parses a cpu list like taskset's, ie: 0-3,6
+func parseCPUList(value string) ([]int, error) {
+	var cpus []int
+
+	for _, part := range strings.Split(value, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		if !isRange {
+			last = first
+		}
+
+		from, err := strconv.Atoi(first)
+		if err != nil || from < 0 {
+			return nil, fmt.Errorf("invalid CRON_CPU_AFFINITY: %s", value)
+		}
+		to, err := strconv.Atoi(last)
+		if err != nil || to < from {
+			return nil, fmt.Errorf("invalid CRON_CPU_AFFINITY: %s", value)
+		}
+
+		for cpu := from; cpu <= to; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return cpus, nil
+}
+
+// parseRlimit parses <soft>[:<hard>], either may be unlimited
+func parseRlimit(name, value string) (rlimit, error) {
+	resource, ok := rlimitResources[name]
+	if !ok {
+		return rlimit{}, fmt.Errorf("rlimits are not supported on this system")
+	}
+
+	softStr, hardStr, hasHard := strings.Cut(value, ":")
+	if !hasHard {
+		hardStr = softStr
+	}
+
+	soft, err := parseRlimitValue(softStr)
+	if err != nil {
+		return rlimit{}, fmt.Errorf("invalid CRON_RLIMIT_%s: %s", strings.ToUpper(name), value)
+	}
+	hard, err := parseRlimitValue(hardStr)
+	if err != nil || soft > hard {
+		return rlimit{}, fmt.Errorf("invalid CRON_RLIMIT_%s: %s", strings.ToUpper(name), value)
+	}
+
+	return rlimit{name: name, resource: resource, soft: soft, hard: hard}, nil
+}
+
+func parseRlimitValue(value string) (uint64, error) {
+	if value == "unlimited" {
+		return math.MaxUint64, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// dryrun prints the options that differ from the runner's own
+func (o *processOptions) dryrun() {
+	if o.dir != "" {
+		fmt.Printf("DRYRUN: Dir: %s\n", o.dir)
+	}
+	if o.umask != nil {
+		fmt.Printf("DRYRUN: Umask: %04o\n", *o.umask)
+	}
+	if o.nice != nil {
+		fmt.Printf("DRYRUN: Nice: %d\n", *o.nice)
+	}
+	if o.ionice != nil {
+		fmt.Printf("DRYRUN: Ionice: %s\n", config.CRON_IONICE)
+	}
+	if o.affinity != nil {
+		fmt.Printf("DRYRUN: CPU Affinity: %v\n", o.affinity)
+	}
+	for _, limit := range o.rlimits {
+		fmt.Printf("DRYRUN: Rlimit %s: %s:%s\n", limit.name, formatRlimitValue(limit.soft), formatRlimitValue(limit.hard))
+	}
+}
+
+func formatRlimitValue(value uint64) string {
+	if value == math.MaxUint64 {
+		return "unlimited"
+	}
+	return strconv.FormatUint(value, 10)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,95 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+var rlimitResources = map[string]int{
+	"nofile": unix.RLIMIT_NOFILE,
+	"nproc":  unix.RLIMIT_NPROC,
+	"core":   unix.RLIMIT_CORE,
+	"as":     unix.RLIMIT_AS,
+}
+
+// ioprio_set(2) targets a single process
+const ioprioWhoProcess = 1
+
+// start starts the command with its scheduling options, rlimits and umask
+// already set when it's executed, so that nothing it starts escapes them
+func (o *processOptions) start(cmd *exec.Cmd) error {
+	// rlimits and the umask are set by the helper the command is executed
+	// from, the runner's own are left alone
+	var helper *execHelper
+	if (len(o.rlimits) > 0 || o.umask != nil) && cmd.Err == nil {
+		var err error
+		if helper, err = wrapExec(cmd, o); err != nil {
+			return fmt.Errorf("%w: %v", errProcessOptions, err)
+		}
+	}
+
+	err := o.startScheduled(cmd)
+	if helper == nil {
+		return err
+	}
+	helper.restore(cmd)
+	if err != nil {
+		helper.status.Close()
+		return err
+	}
+	return helper.wait(cmd)
+}
+
+// startScheduled starts the command from a thread with its nice, ionice and
+// cpu affinity, all per thread and inherited by the child forked from it
+func (o *processOptions) startScheduled(cmd *exec.Cmd) error {
+	if o.nice == nil && o.ionice == nil && o.affinity == nil {
+		return cmd.Start()
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		// never unlocked, the thread is thrown away with its scheduling once
+		// the goroutine returns instead of running anything else
+		runtime.LockOSThread()
+		if err := o.schedule(); err != nil {
+			errc <- err
+			return
+		}
+		errc <- cmd.Start()
+	}()
+	return <-errc
+}
+
+// schedule sets the scheduling options of the calling thread
+func (o *processOptions) schedule() error {
+	if o.nice != nil {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, 0, *o.nice); err != nil {
+			return fmt.Errorf("%w: error setting nice to %d: %v", errProcessOptions, *o.nice, err)
+		}
+	}
+
+	if o.ionice != nil {
+		prio := o.ionice[0]<<13 | o.ionice[1]
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, 0, uintptr(prio)); errno != 0 {
+			return fmt.Errorf("%w: error setting ionice: %v", errProcessOptions, errno)
+		}
+	}
+
+	if o.affinity != nil {
+		var set unix.CPUSet
+		for _, cpu := range o.affinity {
+			set.Set(cpu)
+		}
+		if err := unix.SchedSetaffinity(0, &set); err != nil {
+			return fmt.Errorf("%w: error setting the cpu affinity to %v: %v", errProcessOptions, o.affinity, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// rlimits are only supported on linux
+var rlimitResources = map[string]int{}
+
+// start starts the command with the options applied before it's executed
+func (o *processOptions) start(cmd *exec.Cmd) error {
+	if o.nice != nil || o.ionice != nil || o.affinity != nil {
+		return fmt.Errorf("%w: nice, ionice and cpu affinity are only supported on linux", errProcessOptions)
+	}
+
+	// the umask is per process and inherited on fork, swap it just for the start
+	if o.umask != nil {
+		umask := syscall.Umask(*o.umask)
+		defer syscall.Umask(umask)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestProcessOptionsChdirUmask(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_CHDIR, t.TempDir())
+	setConfig(t, &config.CRON_UMASK, "077")
+	umask := syscall.Umask(022)
+	syscall.Umask(umask)
+
+	cron, _ := New([]string{"sh", "-c", `echo "$(pwd) $(umask)" > result`})
+	cron.Run()
+
+	data, _ := os.ReadFile(filepath.Join(config.CRON_CHDIR, "result"))
+	if expected := config.CRON_CHDIR + " 0077\n"; string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+
+	// the runner keeps its own umask
+	if restored := syscall.Umask(umask); restored != umask {
+		t.Errorf("Expected the runner's umask to be restored to %04o, got %04o", umask, restored)
+	}
+}
+
+func TestProcessOptionsSchedulingAndRlimits(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_CHDIR, t.TempDir())
+	setConfig(t, &config.CRON_NICE, "5")
+	setConfig(t, &config.CRON_CPU_AFFINITY, "0")
+	setConfig(t, &config.CRON_RLIMIT_NOFILE, "100:200")
+	setConfig(t, &config.CRON_RLIMIT_CORE, "0")
+
+	script := `grep -E '^Cpus_allowed_list' /proc/self/status > result; ulimit -Sn >> result; ulimit -Hn >> result; ulimit -c >> result; cut -d' ' -f19 /proc/self/stat >> result`
+	cron, _ := New([]string{"sh", "-c", script})
+	cron.Run()
+
+	data, _ := os.ReadFile(filepath.Join(config.CRON_CHDIR, "result"))
+	if expected := "Cpus_allowed_list:\t0\n100\n200\n0\n5\n"; string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+}
+
+func TestProcessOptionsRlimitsAsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("dropping privileges needs root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no nobody user")
+	}
+
+	setConfig(t, &config.CRON_USER, "nobody")
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_RLIMIT_NOFILE, "100:200")
+
+	result := filepath.Join(nobodyWritableDir(t), "result")
+
+	cron, _ := New([]string{"sh", "-c", `echo "$(id -u) $(ulimit -Sn) $(ulimit -Hn)" > ` + result})
+	cron.Run()
+
+	data, _ := os.ReadFile(result)
+	if expected := nobody.Uid + " 100 200\n"; string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+}
+
+func TestProcessOptionsNotApplied(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_CHDIR, t.TempDir())
+
+	// more than the kernel allows, even to root
+	setConfig(t, &config.CRON_RLIMIT_NOFILE, "unlimited")
+	ran := filepath.Join(config.CRON_CHDIR, "ran")
+	cron, _ := New([]string{"touch", ran})
+	cron.Run()
+
+	if cron.ExitCode != CRON_EXITCODE_PROCESS_OPTIONS || cron.LaunchFailure != CRON_LAUNCH_PROCESS_OPTIONS {
+		t.Errorf("Expected exit code %d and reason %s, got %d and %s", CRON_EXITCODE_PROCESS_OPTIONS, CRON_LAUNCH_PROCESS_OPTIONS, cron.ExitCode, cron.LaunchFailure)
+	}
+	if _, err := os.Stat(ran); err == nil {
+		t.Errorf("Expected the command not to run")
+	}
+
+	// the helper reports why it couldn't execute the command the way os/exec would
+	setConfig(t, &config.CRON_RLIMIT_NOFILE, "100")
+	script := filepath.Join(config.CRON_CHDIR, "script")
+	os.WriteFile(script, []byte("#!/bin/sh\n"), 0644)
+	cron, _ = New([]string{script})
+	cron.Run()
+
+	if cron.ExitCode != CRON_EXITCODE_PERM_DENIED || cron.LaunchFailure != CRON_LAUNCH_PERM_DENIED {
+		t.Errorf("Expected exit code %d and reason %s, got %d and %s", CRON_EXITCODE_PERM_DENIED, CRON_LAUNCH_PERM_DENIED, cron.ExitCode, cron.LaunchFailure)
+	}
+}
+
+func TestProcessOptionsBadWorkdir(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_CHDIR, "/does/not/exist")
+
+	cron, _ := New([]string{"true"})
+	cron.Run()
+
+	if cron.ExitCode != CRON_EXITCODE_BAD_WORKDIR || cron.LaunchFailure != CRON_LAUNCH_BAD_WORKDIR {
+		t.Errorf("Expected exit code %d and reason %s, got %d and %s", CRON_EXITCODE_BAD_WORKDIR, CRON_LAUNCH_BAD_WORKDIR, cron.ExitCode, cron.LaunchFailure)
+	}
+}
+
+func TestProcessOptionsBadWorkdirAsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("dropping privileges needs root")
+	}
+	if _, err := user.Lookup("nobody"); err != nil {
+		t.Skip("no nobody user")
+	}
+
+	setConfig(t, &config.CRON_USER, "nobody")
+	setConfig(t, &config.CRON_METRICS, false)
+
+	// root can enter it, nobody can't
+	setConfig(t, &config.CRON_CHDIR, t.TempDir())
+	os.Chmod(config.CRON_CHDIR, 0700)
+
+	cron, _ := New([]string{"true"})
+	cron.Run()
+
+	if cron.ExitCode != CRON_EXITCODE_BAD_WORKDIR || cron.LaunchFailure != CRON_LAUNCH_BAD_WORKDIR {
+		t.Errorf("Expected exit code %d and reason %s, got %d and %s", CRON_EXITCODE_BAD_WORKDIR, CRON_LAUNCH_BAD_WORKDIR, cron.ExitCode, cron.LaunchFailure)
+	}
+}
+
+func TestProcessOptionsInvalid(t *testing.T) {
+	for _, invalid := range []struct {
+		option *string
+		value  string
+	}{
+		{&config.CRON_UMASK, "999"},
+		{&config.CRON_NICE, "20"},
+		{&config.CRON_IONICE, "fast"},
+		{&config.CRON_IONICE, "best-effort:8"},
+		{&config.CRON_CPU_AFFINITY, "3-1"},
+		{&config.CRON_RLIMIT_NOFILE, "200:100"},
+		{&config.CRON_RLIMIT_AS, "lots"},
+	} {
+		t.Run(invalid.value, func(t *testing.T) {
+			setConfig(t, invalid.option, invalid.value)
+
+			if _, err := New([]string{"true"}); err == nil {
+				t.Errorf("Expected an error for the invalid option %s, got nil", invalid.value)
+			}
+		})
+	}
+}
+
+func TestParseIonice(t *testing.T) {
+	for value, expected := range map[string][2]int{
+		"idle":          {3, 0},
+		"best-effort":   {2, 4},
+		"best-effort:7": {2, 7},
+		"realtime:0":    {1, 0},
+	} {
+		if got, err := parseIonice(value); err != nil || got != expected {
+			t.Errorf("Expected %v for %s, got %v (%v)", expected, value, got, err)
+		}
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := parseCPUList("0-2, 5,7-7")
+	if expected := []int{0, 1, 2, 5, 7}; err != nil || !slices.Equal(cpus, expected) {
+		t.Errorf("Expected %v, got %v (%v)", expected, cpus, err)
+	}
+}
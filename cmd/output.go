@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/monitor"
+)
+
+// lines longer than this are passed on in chunks instead of growing the buffer forever
+const maxLineLength = 64 * 1024
+
+// lineObserver is told about every line the command writes, before redaction
+type lineObserver interface {
+	observe(stream string, line string)
+}
+
+// outputStream sits between the command and the runner's stdout/stderr and
+// passes the output on line by line so that each line can be inspected
+type outputStream struct {
+	name      string // stdout or stderr
+	out       io.Writer
+	redactor  *Redactor
+	observers []lineObserver
+	terminal  bool // written to a terminal, escape sequences are stripped
+
+	mu        sync.Mutex
+	buf       []byte
+	long      []byte // start of a line longer than maxLineLength, already passed on
+	bytes     int64  // written by the command
+	lines     int64
+	lastWrite time.Time
+	lastLine  string
+}
+
+func newOutputStream(name string, out io.Writer, redactor *Redactor, observers ...lineObserver) *outputStream {
+	return &outputStream{
+		name:      name,
+		out:       out,
+		redactor:  redactor,
+		observers: observers,
+	}
+}
+
+// Write buffers the output and handles every complete line
+func (s *outputStream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf = append(s.buf, p...)
+	s.bytes += int64(len(p))
+	s.lastWrite = time.Now()
+
+	for {
+		i := bytes.IndexByte(s.buf, '\n')
+		if i < 0 && len(s.buf) < maxLineLength {
+			break
+		}
+
+		var err error
+		if i < 0 {
+			err = s.chunk(s.buf[:maxLineLength])
+			s.buf = s.buf[maxLineLength:]
+		} else {
+			err = s.line(s.buf[:i+1])
+			s.buf = s.buf[i+1:]
+		}
+		if err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush handles the last line if the command didn't end it with a newline
+func (s *outputStream) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.buf) == 0 && s.long == nil {
+		return nil
+	}
+
+	err := s.line(s.buf)
+	s.buf = nil
+
+	return err
+}
+
+// line passes a line of output, or the rest of a long one, on to the
+// runner's output. A long line is counted and inspected once, by its start.
+func (s *outputStream) line(line []byte) error {
+	line = s.strip(line)
+
+	text := string(bytes.TrimRight(line, "\r\n"))
+	if s.long != nil {
+		text, s.long = string(s.long), nil
+	}
+
+	s.lines++
+	s.lastLine = text
+
+	for _, observer := range s.observers {
+		observer.observe(s.name, s.lastLine)
+	}
+
+	return s.write(line)
+}
+
+// chunk passes on part of a line longer than maxLineLength right away
+// instead of growing the buffer forever
+func (s *outputStream) chunk(chunk []byte) error {
+	chunk = s.strip(chunk)
+
+	if s.long == nil {
+		s.long = append([]byte{}, chunk...)
+	}
+
+	return s.write(chunk)
+}
+
+// strip removes the escape sequences of output written to a terminal
+func (s *outputStream) strip(line []byte) []byte {
+	if !s.terminal {
+		return line
+	}
+
+	stripped := stripTerminal(string(line))
+	if bytes.HasSuffix(line, []byte("\n")) {
+		stripped += "\n"
+	}
+	return []byte(stripped)
+}
+
+// write redacts the output, if enabled, and writes it to the runner's output
+func (s *outputStream) write(line []byte) error {
+	if s.redactor != nil {
+		line = []byte(s.redactor.String(string(line)))
+	}
+
+	_, err := s.out.Write(line)
+	return err
+}
+
+// stats returns the bytes and lines the command wrote so far
+func (s *outputStream) stats() (int64, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bytes, s.lines
+}
+
+// last returns when the command last wrote to the stream and the last line it
+// wrote, including a line it hasn't finished yet
+func (s *outputStream) last() (time.Time, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.buf) > 0 && s.terminal {
+		return s.lastWrite, stripTerminal(string(s.buf))
+	}
+	if len(s.buf) > 0 {
+		return s.lastWrite, string(bytes.TrimRight(s.buf, "\r\n"))
+	}
+	return s.lastWrite, s.lastLine
+}
+
+// outputPattern is a named regex whose matching lines are counted
+type outputPattern struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// outputPatterns counts the lines of output matching each pattern per stream
+type outputPatterns struct {
+	patterns []outputPattern
+
+	mu     sync.Mutex
+	counts map[string]map[string]int64 // stream -> pattern -> lines
+}
+
+// parseOutputPatterns parses CRON_OUTPUT_PATTERNS, semicolon separated
+// <name>=<regex> ie: warning=(?i)\bwarn;error=(?i)\berror
+// semicolons because regexes are full of commas
+func parseOutputPatterns(value string) (*outputPatterns, error) {
+	op := &outputPatterns{counts: make(map[string]map[string]int64)}
+	seen := make(map[string]bool)
+
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		name, expr, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !found || expr == "" {
+			return nil, fmt.Errorf("invalid CRON_OUTPUT_PATTERNS entry %q: expected <name>=<regex>", entry)
+		}
+
+		if ok, _ := regexp.MatchString("^[a-zA-Z_][a-zA-Z0-9_]*$", name); !ok || seen[name] {
+			return nil, fmt.Errorf("invalid CRON_OUTPUT_PATTERNS entry %q: names must be unique letters, digits and underscores", entry)
+		}
+		seen[name] = true
+
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRON_OUTPUT_PATTERNS entry %q: %v", entry, err)
+		}
+
+		op.patterns = append(op.patterns, outputPattern{name, pattern})
+	}
+
+	return op, nil
+}
+
+// observe counts a single line of output
+func (op *outputPatterns) observe(stream string, line string) {
+	for _, p := range op.patterns {
+		if !p.pattern.MatchString(line) {
+			continue
+		}
+
+		op.mu.Lock()
+		if op.counts[stream] == nil {
+			op.counts[stream] = make(map[string]int64)
+		}
+		op.counts[stream][p.name]++
+		op.mu.Unlock()
+	}
+}
+
+// count returns the lines of a stream matching the pattern so far
+func (op *outputPatterns) count(stream, name string) int64 {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	return op.counts[stream][name]
+}
+
+// setOutputMetrics sets the output line and byte metrics of both streams
+func (c *Cron) setOutputMetrics() {
+	for _, stream := range []*outputStream{c.stdout, c.stderr} {
+		if stream == nil {
+			continue
+		}
+
+		bytes, lines := stream.stats()
+		monitor.CronOutputBytes.WithLabelValues(c.Monitor.Namespace, stream.name).Set(float64(bytes))
+		monitor.CronOutputTotalLines.WithLabelValues(c.Monitor.Namespace, stream.name).Set(float64(lines))
+
+		for _, p := range c.outputPatterns.patterns {
+			monitor.CronOutputLines.WithLabelValues(c.Monitor.Namespace, stream.name, p.name).Set(float64(c.outputPatterns.count(stream.name, p.name)))
+		}
+	}
+}
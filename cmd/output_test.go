@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestOutputPatterns(t *testing.T) {
+	setConfig(t, &config.CRON_OUTPUT_PATTERNS, `warning=(?i)\bwarn;error=(?i)\berror\b;count=\d{1,3},\d{3}`)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	script := "echo 'WARN: disk 80% full'; echo 'processed 1,000 rows'; echo 'error: retrying' >&2; echo 'Error: gave up' >&2; printf 'no newline'"
+	cron, _ := New([]string{"sh", "-c", script})
+	cron.Run()
+
+	expected := map[string]map[string]int64{
+		"stdout": {"warning": 1, "error": 0, "count": 1},
+		"stderr": {"warning": 0, "error": 2, "count": 0},
+	}
+	for stream, patterns := range expected {
+		for name, count := range patterns {
+			if got := cron.outputPatterns.count(stream, name); got != count {
+				t.Errorf("Expected %d %s lines on %s, got %d", count, name, stream, got)
+			}
+		}
+	}
+
+	bytes, lines := cron.stdout.stats()
+	if bytes != int64(len("WARN: disk 80% full\nprocessed 1,000 rows\nno newline")) || lines != 3 {
+		t.Errorf("Expected 51 bytes and 3 lines on stdout, got %d bytes and %d lines", bytes, lines)
+	}
+}
+
+func TestOutputStreamLongLines(t *testing.T) {
+	op, _ := parseOutputPatterns("error=^error")
+	var out strings.Builder
+	stream := newOutputStream("stdout", &out, nil, op)
+
+	long := "error: " + strings.Repeat("x", 2*maxLineLength)
+	stream.Write([]byte(long + "\nshort\n" + long))
+	stream.Flush()
+
+	if out.String() != long+"\nshort\n"+long {
+		t.Errorf("Expected the output to be passed on unchanged")
+	}
+	if _, lines := stream.stats(); lines != 3 {
+		t.Errorf("Expected 3 lines, got %d", lines)
+	}
+	if got := op.count("stdout", "error"); got != 2 {
+		t.Errorf("Expected 2 error lines, got %d", got)
+	}
+}
+
+func TestOutputPatternsInvalid(t *testing.T) {
+	for _, patterns := range []string{"error", "=error", "bad-name=error", "error=(", "a=x;a=y"} {
+		setConfig(t, &config.CRON_OUTPUT_PATTERNS, patterns)
+
+		if _, err := New([]string{"true"}); err == nil {
+			t.Errorf("Expected an error for CRON_OUTPUT_PATTERNS=%s, got nil", patterns)
+		}
+	}
+}
+
+func TestOutputPatternsMetrics(t *testing.T) {
+	setConfig(t, &config.CRON_OUTPUT_PATTERNS, "error=ERROR")
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE, "output_patterns")
+
+	cron, _ := New([]string{"sh", "-c", "echo ERROR >&2"})
+	cron.Run()
+
+	data, _ := os.ReadFile(filepath.Join(config.CRON_METRICS_DIR, "cron_output_patterns_metrics.prom"))
+	for _, metric := range []string{
+		`cron_output_lines{namespace="output_patterns",pattern="error",stream="stderr"} 1`,
+		`cron_output_lines{namespace="output_patterns",pattern="error",stream="stdout"} 0`,
+		`cron_output_total_lines{namespace="output_patterns",stream="stderr"} 1`,
+		`cron_output_bytes{namespace="output_patterns",stream="stderr"} 6`,
+	} {
+		if !strings.Contains(string(data), metric) {
+			t.Errorf("Expected metrics file to contain %s", metric)
+		}
+	}
+}
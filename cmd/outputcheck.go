@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// OUTPUT CHECK FAILURES
+const (
+	CRON_OUTPUT_MUST_MATCH     = "must_match"       // no line matched CRON_OUTPUT_MUST_MATCH
+	CRON_OUTPUT_MUST_NOT_MATCH = "must_not_match"   // a line matched CRON_OUTPUT_MUST_NOT_MATCH
+	CRON_OUTPUT_STDERR_EMPTY   = "stderr_not_empty" // CRON_STDERR_EMPTY and the command wrote to stderr
+)
+
+// OutputCheck is the result of a failed output check
+type OutputCheck struct {
+	Reason string `json:"reason"`
+	Stream string `json:"stream,omitempty"`
+	Line   string `json:"line,omitempty"` // the offending line, redacted
+}
+
+// outputCheck decides success based on what the command printed, for scripts
+// that always exit 0 even when they print ERROR
+type outputCheck struct {
+	mustMatch    *regexp.Regexp
+	mustNotMatch *regexp.Regexp
+	stderrEmpty  bool
+
+	mu      sync.Mutex
+	matched bool         // a line matched mustMatch
+	failure *OutputCheck // the first line that failed the check
+}
+
+// newOutputCheck builds the output check from the config, nil if there's nothing to check
+func newOutputCheck() (*outputCheck, error) {
+	check := &outputCheck{stderrEmpty: config.CRON_STDERR_EMPTY}
+
+	var err error
+	if config.CRON_OUTPUT_MUST_MATCH != "" {
+		if check.mustMatch, err = regexp.Compile(config.CRON_OUTPUT_MUST_MATCH); err != nil {
+			return nil, fmt.Errorf("invalid CRON_OUTPUT_MUST_MATCH: %v", err)
+		}
+	}
+	if config.CRON_OUTPUT_MUST_NOT_MATCH != "" {
+		if check.mustNotMatch, err = regexp.Compile(config.CRON_OUTPUT_MUST_NOT_MATCH); err != nil {
+			return nil, fmt.Errorf("invalid CRON_OUTPUT_MUST_NOT_MATCH: %v", err)
+		}
+	}
+
+	if check.mustMatch == nil && check.mustNotMatch == nil && !check.stderrEmpty {
+		return nil, nil
+	}
+
+	return check, nil
+}
+
+// observe checks a single line of output
+func (oc *outputCheck) observe(stream string, line string) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	if oc.mustMatch != nil && oc.mustMatch.MatchString(line) {
+		oc.matched = true
+	}
+
+	// only the first failure is kept
+	if oc.failure != nil {
+		return
+	}
+
+	switch {
+	case oc.mustNotMatch != nil && oc.mustNotMatch.MatchString(line):
+		oc.failure = &OutputCheck{CRON_OUTPUT_MUST_NOT_MATCH, stream, line}
+	case oc.stderrEmpty && stream == "stderr":
+		oc.failure = &OutputCheck{CRON_OUTPUT_STDERR_EMPTY, stream, line}
+	}
+}
+
+// result returns why the output check failed, nil if it passed
+func (oc *outputCheck) result() *OutputCheck {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	if oc.failure != nil {
+		return oc.failure
+	}
+
+	if oc.mustMatch != nil && !oc.matched {
+		return &OutputCheck{Reason: CRON_OUTPUT_MUST_MATCH}
+	}
+
+	return nil
+}
+
+// applyOutputCheck fails a command that exited successfully if its output says otherwise
+func (c *Cron) applyOutputCheck() {
+	if c.outputCheck == nil {
+		return
+	}
+
+	if c.StatusCode != CRON_STATUS_SUCCESS && c.StatusCode != CRON_STATUS_WARNING {
+		return
+	}
+
+	if failure := c.outputCheck.result(); failure != nil {
+		redacted := *failure
+		redacted.Line = c.redactor.String(failure.Line)
+		c.OutputCheck = &redacted
+		c.StatusCode = CRON_STATUS_FAIL
+		c.ExitCode = CRON_EXITCODE_OUTPUT_CHECK
+	}
+}
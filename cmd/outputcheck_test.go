@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func runOutputCheck(t *testing.T, script string) *Cron {
+	cron, err := New([]string{"sh", "-c", script})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cron.Run()
+	return cron
+}
+
+func TestOutputMustNotMatch(t *testing.T) {
+	setConfig(t, &config.CRON_OUTPUT_MUST_NOT_MATCH, "ERROR")
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron := runOutputCheck(t, "echo starting; echo 'ERROR: db unreachable --password=hunter2'; exit 0")
+
+	if cron.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_FAIL, cron.StatusCode)
+	}
+
+	if cron.ExitCode != CRON_EXITCODE_OUTPUT_CHECK {
+		t.Errorf("Expected exit code %d, got %d", CRON_EXITCODE_OUTPUT_CHECK, cron.ExitCode)
+	}
+
+	expected := OutputCheck{CRON_OUTPUT_MUST_NOT_MATCH, "stdout", "ERROR: db unreachable --password=" + REDACTED}
+	if cron.OutputCheck == nil || *cron.OutputCheck != expected {
+		t.Errorf("Expected output check %+v, got %+v", expected, cron.OutputCheck)
+	}
+}
+
+func TestOutputMustMatch(t *testing.T) {
+	setConfig(t, &config.CRON_OUTPUT_MUST_MATCH, `^done: \d+ rows$`)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron := runOutputCheck(t, "echo 'done: 42 rows'")
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+
+	cron = runOutputCheck(t, "echo 'done: ? rows'")
+	if cron.StatusCode != CRON_STATUS_FAIL || cron.OutputCheck == nil || cron.OutputCheck.Reason != CRON_OUTPUT_MUST_MATCH {
+		t.Errorf("Expected status code %d with reason %s, got %d (%+v)", CRON_STATUS_FAIL, CRON_OUTPUT_MUST_MATCH, cron.StatusCode, cron.OutputCheck)
+	}
+}
+
+func TestOutputStderrEmpty(t *testing.T) {
+	setConfig(t, &config.CRON_STDERR_EMPTY, true)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron := runOutputCheck(t, "echo fine; echo 'deprecated option' >&2")
+
+	if cron.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_FAIL, cron.StatusCode)
+	}
+
+	if cron.OutputCheck == nil || cron.OutputCheck.Stream != "stderr" || cron.OutputCheck.Line != "deprecated option" {
+		t.Errorf("Expected the stderr line in the output check, got %+v", cron.OutputCheck)
+	}
+}
+
+// TestOutputCheckKeepsFailure tests that a failed command keeps its own exit code
+func TestOutputCheckKeepsFailure(t *testing.T) {
+	setConfig(t, &config.CRON_OUTPUT_MUST_NOT_MATCH, "ERROR")
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron := runOutputCheck(t, "echo ERROR; exit 4")
+
+	if cron.ExitCode != 4 || cron.OutputCheck != nil {
+		t.Errorf("Expected exit code 4 and no output check, got %d (%+v)", cron.ExitCode, cron.OutputCheck)
+	}
+}
+
+func TestOutputCheckInvalidRegex(t *testing.T) {
+	setConfig(t, &config.CRON_OUTPUT_MUST_MATCH, "(")
+	setConfig(t, &config.CRON_METRICS, false)
+
+	if _, err := New([]string{"true"}); err == nil {
+		t.Errorf("Expected an error for an invalid CRON_OUTPUT_MUST_MATCH, got nil")
+	}
+}
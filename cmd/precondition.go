@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+	"github.com/devinodaniel/cron-go/cmd/monitor"
+	"golang.org/x/sys/unix"
+)
+
+// PRECONDITION FAILURE REASONS
+// exposed as the reason label of the cron_precondition_failed metric
+const (
+	CRON_PRECONDITION_FILE    = "file"
+	CRON_PRECONDITION_DISK    = "disk_space"
+	CRON_PRECONDITION_TCP     = "tcp"
+	CRON_PRECONDITION_COMMAND = "command"
+)
+
+// PRECONDITION ACTIONS
+const (
+	CRON_REQUIRE_SKIP = "skip" // the run is SKIPPED, nothing to do this time
+	CRON_REQUIRE_FAIL = "fail" // the run is PRECONDITION_FAILED, something is wrong
+)
+
+// Precondition is the CRON_REQUIRE_* check that kept the command from running
+type Precondition struct {
+	Reason  string `json:"reason"` // file, disk_space, tcp or command
+	Message string `json:"message"`
+}
+
+// requirement is a single CRON_REQUIRE_* check of the run, check returns why
+// it failed
+type requirement struct {
+	reason string
+	check  func(ctx context.Context, c *Cron) error
+}
+
+// parsePreconditions builds the CRON_REQUIRE_* checks in the order they run:
+// the cheap local ones first
+func parsePreconditions() ([]requirement, error) {
+	var preconditions []requirement
+
+	switch config.CRON_REQUIRE_ACTION {
+	case CRON_REQUIRE_SKIP, CRON_REQUIRE_FAIL:
+	default:
+		return nil, fmt.Errorf("invalid CRON_REQUIRE_ACTION: %s", config.CRON_REQUIRE_ACTION)
+	}
+
+	for _, path := range config.CRON_REQUIRE_FILE {
+		preconditions = append(preconditions, requirement{CRON_PRECONDITION_FILE, func(context.Context, *Cron) error {
+			return requireFile(path)
+		}})
+	}
+
+	for _, entry := range config.CRON_REQUIRE_DISK {
+		// the path may contain colons, the size doesn't
+		i := strings.LastIndex(entry, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid CRON_REQUIRE_DISK entry %q: expected <path>:<size>", entry)
+		}
+		path := entry[:i]
+		size, err := parseBytes(entry[i+1:])
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid CRON_REQUIRE_DISK entry %q: expected <path>:<size>", entry)
+		}
+
+		preconditions = append(preconditions, requirement{CRON_PRECONDITION_DISK, func(context.Context, *Cron) error {
+			return requireDisk(path, size)
+		}})
+	}
+
+	for _, addr := range config.CRON_REQUIRE_TCP {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("invalid CRON_REQUIRE_TCP entry %q: expected <host>:<port>", addr)
+		}
+
+		preconditions = append(preconditions, requirement{CRON_PRECONDITION_TCP, func(ctx context.Context, _ *Cron) error {
+			return requireTCP(ctx, addr)
+		}})
+	}
+
+	if command := config.CRON_REQUIRE_CMD; command != "" {
+		preconditions = append(preconditions, requirement{CRON_PRECONDITION_COMMAND, func(ctx context.Context, c *Cron) error {
+			return c.requireCommand(ctx, command)
+		}})
+	}
+
+	return preconditions, nil
+}
+
+func requireFile(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s doesn't exist", path)
+	}
+	return nil
+}
+
+func requireDisk(path string, size int64) error {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return fmt.Errorf("free space of %s unknown: %v", path, err)
+	}
+
+	// what an unprivileged user can use, not counting the reserved blocks
+	if free := uint64(stat.Bavail) * uint64(stat.Bsize); free < uint64(size) {
+		return fmt.Errorf("%d bytes free on %s, need %d", free, path, size)
+	}
+	return nil
+}
+
+func requireTCP(ctx context.Context, addr string) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("%s unreachable: %v", addr, err)
+	}
+	conn.Close()
+	return nil
+}
+
+// requireCommand runs the check the way the command would run, its output
+// isn't passed on
+func (c *Cron) requireCommand(ctx context.Context, command string) error {
+	cmd, err := c.jobCommand(ctx, command)
+	if err != nil {
+		return fmt.Errorf("check command not run: %v", err)
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("check command failed: %v", err)
+	}
+	return nil
+}
+
+// checkPreconditions runs the checks until one fails, each gets at most
+// CRON_REQUIRE_TIMEOUT seconds
+func (c *Cron) checkPreconditions() (string, error) {
+	for _, p := range c.preconditions {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.CRON_REQUIRE_TIMEOUT)*time.Second)
+		err := p.check(ctx, c)
+		cancel()
+
+		if err != nil {
+			return p.reason, err
+		}
+	}
+	return "", nil
+}
+
+// applyPreconditions returns false if the command shouldn't run and sets the
+// status saying why
+func (c *Cron) applyPreconditions() bool {
+	reason, err := c.checkPreconditions()
+	if err == nil {
+		return true
+	}
+
+	c.Precondition = &Precondition{Reason: reason, Message: c.redactor.String(err.Error())}
+	c.ExitCode = CRON_EXITCODE_UNKNOWN
+	if config.CRON_REQUIRE_ACTION == CRON_REQUIRE_FAIL {
+		c.StatusCode = CRON_STATUS_PRECONDITION_FAILED
+		c.logf("ERROR: precondition failed: %s\n", c.Precondition.Message)
+	} else {
+		c.StatusCode = CRON_STATUS_SKIPPED
+		c.logf("WARNING: skipped, precondition not met: %s\n", c.Precondition.Message)
+	}
+
+	return false
+}
+
+// setPreconditionMetrics sets the reason the command didn't run
+func (c *Cron) setPreconditionMetrics() {
+	if c.Precondition == nil {
+		return
+	}
+
+	monitor.CronPreconditionFailed.WithLabelValues(c.Monitor.Namespace, c.Precondition.Reason).Set(1)
+}
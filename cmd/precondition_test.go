@@ -0,0 +1,206 @@
+package main
+
+import (
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// runPreconditions runs a command that leaves a file behind and returns
+// whether it ran
+func runPreconditions(t *testing.T) (*Cron, bool) {
+	ran := filepath.Join(t.TempDir(), "ran")
+	cron, err := New([]string{"touch", ran})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cron.Run()
+
+	_, err = os.Stat(ran)
+	return cron, err == nil
+}
+
+func TestRunPreconditionSkipped(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE, "precondition_skipped")
+
+	setConfig(t, &config.CRON_REQUIRE_FILE, []string{"/nonexistent/ready"})
+	cron, ran := runPreconditions(t)
+
+	if ran {
+		t.Fatal("Expected the command not to run")
+	}
+	if cron.StatusCode != CRON_STATUS_SKIPPED || cron.ExitCode != CRON_EXITCODE_UNKNOWN {
+		t.Errorf("Expected status code %d and exit code %d, got %d and %d", CRON_STATUS_SKIPPED, CRON_EXITCODE_UNKNOWN, cron.StatusCode, cron.ExitCode)
+	}
+	if cron.Precondition == nil || cron.Precondition.Reason != CRON_PRECONDITION_FILE {
+		t.Errorf("Expected reason %s, got %+v", CRON_PRECONDITION_FILE, cron.Precondition)
+	}
+
+	metrics, _ := os.ReadFile(filepath.Join(config.CRON_METRICS_DIR, "cron_precondition_skipped_metrics.prom"))
+	for _, expected := range []string{
+		`cron_precondition_failed{namespace="precondition_skipped",reason="file"} 1`,
+		`cron_status{code="10",namespace="precondition_skipped",status="SKIPPED"} 1`,
+	} {
+		if !strings.Contains(string(metrics), expected) {
+			t.Errorf("Expected %s in the metrics", expected)
+		}
+	}
+}
+
+func TestRunPreconditionFailed(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_REQUIRE_ACTION, CRON_REQUIRE_FAIL)
+	setConfig(t, &config.CRON_REQUIRE_CMD, "test -d /nonexistent")
+
+	cron, ran := runPreconditions(t)
+
+	if ran {
+		t.Fatal("Expected the command not to run")
+	}
+	if cron.StatusCode != CRON_STATUS_PRECONDITION_FAILED {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_PRECONDITION_FAILED, cron.StatusCode)
+	}
+	if cron.Precondition == nil || cron.Precondition.Reason != CRON_PRECONDITION_COMMAND {
+		t.Errorf("Expected reason %s, got %+v", CRON_PRECONDITION_COMMAND, cron.Precondition)
+	}
+}
+
+func TestRunPreconditionsMet(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	setConfig(t, &config.CRON_REQUIRE_FILE, []string{t.TempDir()})
+	setConfig(t, &config.CRON_REQUIRE_DISK, []string{t.TempDir() + ":1K"})
+	setConfig(t, &config.CRON_REQUIRE_TCP, []string{listener.Addr().String()})
+	setConfig(t, &config.CRON_REQUIRE_CMD, "true")
+
+	cron, ran := runPreconditions(t)
+
+	if !ran || cron.StatusCode != CRON_STATUS_SUCCESS || cron.Precondition != nil {
+		t.Errorf("Expected the command to run with status code %d, got %d %+v", CRON_STATUS_SUCCESS, cron.StatusCode, cron.Precondition)
+	}
+}
+
+func TestRunPreconditionCommandAsJob(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("dropping privileges needs root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no nobody user")
+	}
+
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_USER, "nobody")
+	setConfig(t, &config.CRON_REQUIRE_ACTION, CRON_REQUIRE_FAIL)
+	t.Setenv("SSH_AUTH_SOCK", "/tmp/ssh-root/agent")
+	setConfig(t, &config.CRON_ENV_FILE, []string{writeEnvFile(t, "ENV_TEST_READY=yes\n")})
+
+	// same user and env as the command, not the runner's
+	setConfig(t, &config.CRON_REQUIRE_CMD, `test "$(id -u)" = `+nobody.Uid+` && test "$ENV_TEST_READY" = yes && test -n "$CRON_RUN_ID" && test -z "$SSH_AUTH_SOCK"`)
+	cron, err := New([]string{"true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS || cron.Precondition != nil {
+		t.Errorf("Expected the check to pass, got status code %d %+v", cron.StatusCode, cron.Precondition)
+	}
+}
+
+func TestRunPreconditionUnknownUser(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_USER, "cron-runner-no-such-user")
+	setConfig(t, &config.CRON_REQUIRE_CMD, "true")
+
+	cron, ran := runPreconditions(t)
+
+	if ran || cron.Precondition != nil {
+		t.Errorf("Expected the command not to run and no precondition to fail, got %+v", cron.Precondition)
+	}
+	if cron.StatusCode != CRON_STATUS_INVALID_USER || cron.ExitCode != CRON_EXITCODE_INVALID_USER {
+		t.Errorf("Expected status code %d and exit code %d, got %d and %d", CRON_STATUS_INVALID_USER, CRON_EXITCODE_INVALID_USER, cron.StatusCode, cron.ExitCode)
+	}
+}
+
+func TestRunPreconditionCommandTimeout(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_REQUIRE_TIMEOUT, 1)
+
+	// whatever the check started is killed with it
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	setConfig(t, &config.CRON_REQUIRE_CMD, "sleep 30 & echo $! > "+pidFile+"; wait")
+	cron, ran := runPreconditions(t)
+
+	if ran || cron.Precondition == nil || cron.Precondition.Reason != CRON_PRECONDITION_COMMAND {
+		t.Errorf("Expected reason %s, got %+v", CRON_PRECONDITION_COMMAND, cron.Precondition)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// killed, but it may take a moment to go away
+	stat := filepath.Join("/proc", strings.TrimSpace(string(data)), "stat")
+	for i := 0; i < 50; i++ {
+		data, err := os.ReadFile(stat)
+		if err != nil || strings.Contains(string(data), ") Z ") {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Errorf("Expected the sleep to be killed with the check")
+}
+
+func TestRunPreconditionDiskAndTCP(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+
+	setConfig(t, &config.CRON_REQUIRE_DISK, []string{t.TempDir() + ":1024T"})
+	cron, ran := runPreconditions(t)
+	if ran || cron.Precondition == nil || cron.Precondition.Reason != CRON_PRECONDITION_DISK {
+		t.Errorf("Expected reason %s, got %+v", CRON_PRECONDITION_DISK, cron.Precondition)
+	}
+
+	// nothing listens once it's closed
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	listener.Close()
+
+	setConfig(t, &config.CRON_REQUIRE_DISK, nil)
+	setConfig(t, &config.CRON_REQUIRE_TCP, []string{listener.Addr().String()})
+	cron, ran = runPreconditions(t)
+	if ran || cron.Precondition == nil || cron.Precondition.Reason != CRON_PRECONDITION_TCP {
+		t.Errorf("Expected reason %s, got %+v", CRON_PRECONDITION_TCP, cron.Precondition)
+	}
+}
+
+func TestParsePreconditionsInvalid(t *testing.T) {
+	for _, setup := range []func(t *testing.T){
+		func(t *testing.T) { setConfig(t, &config.CRON_REQUIRE_DISK, []string{"/backup"}) },
+		func(t *testing.T) { setConfig(t, &config.CRON_REQUIRE_DISK, []string{"/backup:"}) },
+		func(t *testing.T) { setConfig(t, &config.CRON_REQUIRE_DISK, []string{"/backup:lots"}) },
+		func(t *testing.T) { setConfig(t, &config.CRON_REQUIRE_TCP, []string{"db.example.com"}) },
+		func(t *testing.T) { setConfig(t, &config.CRON_REQUIRE_ACTION, "ignore") },
+	} {
+		t.Run("", func(t *testing.T) {
+			setConfig(t, &config.CRON_METRICS, false)
+			setup(t)
+			if _, err := New([]string{"true"}); err == nil {
+				t.Errorf("Expected an error for %q %q %s", config.CRON_REQUIRE_DISK, config.CRON_REQUIRE_TCP, config.CRON_REQUIRE_ACTION)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"syscall"
+
+	"github.com/prometheus/procfs"
+)
+
+// processTree returns pid and the pids of all its descendants by walking /proc
+// the command's own children aren't in its process group when they daemonize
+// or call setsid, but they're still its descendants
+func processTree(pid int) []int {
+	tree := []int{pid}
+
+	fs, err := procfs.NewDefaultFS()
+	if err != nil {
+		return tree
+	}
+
+	procs, err := fs.AllProcs()
+	if err != nil {
+		return tree
+	}
+
+	children := make(map[int][]int)
+	for _, proc := range procs {
+		stat, err := proc.Stat()
+		if err != nil {
+			// the process exited while we were looking
+			continue
+		}
+		children[stat.PPID] = append(children[stat.PPID], stat.PID)
+	}
+
+	for i := 0; i < len(tree); i++ {
+		tree = append(tree, children[tree[i]]...)
+	}
+
+	return tree
+}
+
+// killTree sends the signal to the process and all its descendants
+// descendants are collected first as they're reparented once their parent dies,
+// the process itself goes first so it can't react to its children dying
+func killTree(pid int, sig syscall.Signal) error {
+	tree := processTree(pid)
+
+	err := syscall.Kill(pid, sig)
+	for _, child := range tree[1:] {
+		syscall.Kill(child, sig)
+	}
+
+	return err
+}
+
+// treeSample returns the resources used right now by the process and all its
+// descendants combined, processes that exited while walking the tree are skipped
+func treeSample(pid int) ResourceSample {
+	var sample ResourceSample
+
+	fs, err := procfs.NewDefaultFS()
+	if err != nil {
+		return sample
+	}
+
+	for _, pid := range processTree(pid) {
+		proc, err := fs.Proc(pid)
+		if err != nil {
+			continue
+		}
+		stat, err := proc.Stat()
+		if err != nil {
+			continue
+		}
+
+		sample.Processes++
+		sample.Threads += stat.NumThreads
+		sample.CPUSeconds += stat.CPUTime()
+		sample.RSSBytes += int64(stat.ResidentMemory())
+
+		if fds, err := proc.FileDescriptorsLen(); err == nil {
+			sample.OpenFDs += fds
+		}
+
+		// only readable for processes of the same user
+		if io, err := proc.IO(); err == nil {
+			sample.ReadBytes += io.ReadBytes
+			sample.WriteBytes += io.WriteBytes
+		}
+	}
+
+	return sample
+}
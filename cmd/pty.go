@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// the size of the terminal, what most tools assume without one
+const (
+	ptyRows = 24
+	ptyCols = 80
+)
+
+// ptyEOF is the VEOF character, the command reads end of file from its
+// terminal like it would from /dev/null
+const ptyEOF = "\x04"
+
+// ansiPattern matches CSI (colors, cursor movement), OSC (titles, links) and
+// the other escape sequences a terminal interprets
+var ansiPattern = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// controlPattern matches the control characters left once the escape
+// sequences are gone, tabs aside
+var controlPattern = regexp.MustCompile(`[\x00-\x08\x0b-\x1f\x7f]`)
+
+// stripTerminal returns a line written to a terminal the way it ends up on
+// screen: without escape sequences, and of a progress bar redrawn with
+// carriage returns only the last state
+func stripTerminal(line string) string {
+	line = ansiPattern.ReplaceAllString(strings.TrimRight(line, "\r\n"), "")
+	if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	return controlPattern.ReplaceAllString(line, "")
+}
+
+// terminal is the pseudo-terminal the command runs attached to with CRON_PTY
+type terminal struct {
+	master *os.File
+	tty    *os.File
+	stdin  bool // the command reads from the terminal too
+	copied chan struct{}
+}
+
+// attachPty makes a new pseudo-terminal the command's stdin, stdout, stderr
+// and controlling terminal, stdin stays what CRON_STDIN or CRON_STDIN_CONTENT
+// say when they're set
+func (c *Cron) attachPty(cmd *exec.Cmd) (*terminal, error) {
+	master, tty, err := openPty()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.runAs.chown(tty.Name()); err != nil {
+		master.Close()
+		tty.Close()
+		return nil, err
+	}
+
+	stdin := config.CRON_STDIN == "" && config.CRON_STDIN_CONTENT == ""
+
+	cmd.Stdout, cmd.Stderr = tty, tty
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 1 // stdout
+	if stdin {
+		cmd.Stdin = tty
+		cmd.SysProcAttr.Ctty = 0 // stdin
+	}
+
+	return &terminal{
+		master: master,
+		tty:    tty,
+		stdin:  stdin,
+		copied: make(chan struct{}),
+	}, nil
+}
+
+// started copies the output of the command to out until the command and
+// everything it started closed the terminal
+func (t *terminal) started(out io.Writer) {
+	// the command has its own copy
+	t.tty.Close()
+	if t.stdin {
+		t.master.WriteString(ptyEOF)
+	}
+
+	go func() {
+		// EIO once the last copy of the tty is closed
+		io.Copy(out, t.master)
+		close(t.copied)
+	}()
+}
+
+// wait waits for the output to be copied, at most delay as background
+// processes may keep the terminal open
+func (t *terminal) wait(delay time.Duration) {
+	select {
+	case <-t.copied:
+	case <-time.After(delay):
+	}
+	t.master.Close()
+	<-t.copied
+}
+
+// close closes the terminal if the command never started
+func (t *terminal) close() {
+	t.master.Close()
+	t.tty.Close()
+}
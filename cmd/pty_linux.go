@@ -0,0 +1,45 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPty opens a new pseudo-terminal, the runner reads from the master and
+// the command gets the tty. Echo is off as nothing is typed into it.
+func openPty() (master, tty *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("unlockpt: %w", err)
+	}
+	n, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("ptsname: %w", err)
+	}
+
+	tty, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+
+	ttyFd := int(tty.Fd())
+	if termios, err := unix.IoctlGetTermios(ttyFd, unix.TCGETS); err == nil {
+		termios.Lflag &^= unix.ECHO
+		unix.IoctlSetTermios(ttyFd, unix.TCSETS, termios)
+	}
+	unix.IoctlSetWinsize(ttyFd, unix.TIOCSWINSZ, &unix.Winsize{Row: ptyRows, Col: ptyCols})
+
+	return master, tty, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// openPty is only supported on linux
+func openPty() (master, tty *os.File, err error) {
+	return nil, nil, errors.New("pseudo-terminals are only supported on linux")
+}
@@ -0,0 +1,93 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestStripTerminal(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		expected string
+	}{
+		{"plain\r\n", "plain"},
+		{"\x1b[1;31mred\x1b[0m and \x1b[32mgreen\x1b[m\n", "red and green"},
+		{"\x1b]0;window title\x07text", "text"},
+		{"\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"  0%\r 50%\r100%\r\n", "100%"},
+		{"\x1b[2K\rdone\tok\x07", "done\tok"},
+	} {
+		if got := stripTerminal(tc.line); got != tc.expected {
+			t.Errorf("Expected %q for %q, got %q", tc.expected, tc.line, got)
+		}
+	}
+}
+
+func TestRunPty(t *testing.T) {
+	setConfig(t, &config.CRON_PTY, true)
+	setConfig(t, &config.CRON_OUTPUT_MUST_MATCH, `^100% done$`)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	// only writes the progress when it's on a terminal, then reads stdin to the end
+	out := filepath.Join(t.TempDir(), "out")
+	script := `test -t 0 && test -t 1 && test -t 2 || exit 9
+printf '\033[32m 50%%\033[0m\r100%% done\n'
+cat > /dev/null
+stty size > ` + out
+	cron, _ := New([]string{"sh", "-c", script})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Fatalf("Expected status code %d, got %d exit code %d", CRON_STATUS_SUCCESS, cron.StatusCode, cron.ExitCode)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "24 80\n" {
+		t.Errorf("Expected a 24x80 terminal, got %q", data)
+	}
+	if _, lines := cron.stdout.stats(); lines != 1 {
+		t.Errorf("Expected 1 line of output, got %d", lines)
+	}
+}
+
+func TestRunPtyStdin(t *testing.T) {
+	setConfig(t, &config.CRON_PTY, true)
+	setConfig(t, &config.CRON_STDIN_CONTENT, "VACUUM;")
+	setConfig(t, &config.CRON_METRICS, false)
+
+	// the configured stdin wins over the terminal
+	out := filepath.Join(t.TempDir(), "out")
+	cron, _ := New([]string{"sh", "-c", "test -t 1 && ! test -t 0 || exit 9; cat > " + out})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Fatalf("Expected status code %d, got %d exit code %d", CRON_STATUS_SUCCESS, cron.StatusCode, cron.ExitCode)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "VACUUM;" {
+		t.Errorf("Expected the content as stdin, got %q", data)
+	}
+}
+
+func TestRunPtyTimeout(t *testing.T) {
+	setConfig(t, &config.CRON_PTY, true)
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_TIMEOUT, 1)
+
+	// a partial line written before the kill still makes it out
+	cron, _ := New([]string{"sh", "-c", "printf 'almost there'; sleep 10"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_TIMEOUT {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_TIMEOUT, cron.StatusCode)
+	}
+	if cron.Duration > 5*time.Second {
+		t.Errorf("Expected the command to be killed after ~1s, took %v", cron.Duration)
+	}
+	if _, last := cron.stdout.last(); last != "almost there" {
+		t.Errorf("Expected the partial line, got %q", last)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+const REDACTED = "[REDACTED]"
+
+// env var values shorter than this are not redacted, replacing every "1" or
+// "yes" in the output would make it unreadable without protecting anything
+const minRedactLength = 4
+
+// secretEnvPattern matches the names of env vars that usually hold secrets
+var secretEnvPattern = regexp.MustCompile(`(?i)(PASSWORD|PASSWD|SECRET|TOKEN|API_?KEY|PRIVATE_?KEY|CREDENTIAL)`)
+
+// Redactor hides secrets from everything the runner prints or persists
+type Redactor struct {
+	pattern     *regexp.Regexp  // CRON_REDACT_REGEX
+	flags       map[string]bool // CRON_REDACT_FLAGS, lowercase without dashes
+	flagPattern *regexp.Regexp  // matches --flag=value and --flag value in a string
+	values      []string        // values of the CRON_REDACT_ENV env vars
+}
+
+// NewRedactor builds a redactor from the CRON_REDACT_* config
+func NewRedactor() (*Redactor, error) {
+	r := &Redactor{flags: make(map[string]bool)}
+
+	if config.CRON_REDACT_REGEX != "" {
+		pattern, err := regexp.Compile(config.CRON_REDACT_REGEX)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRON_REDACT_REGEX: %v", err)
+		}
+		r.pattern = pattern
+	}
+
+	var names []string
+	for _, flag := range config.CRON_REDACT_FLAGS {
+		flag = strings.ToLower(strings.TrimLeft(flag, "-"))
+		if flag == "" {
+			continue
+		}
+		r.flags[flag] = true
+		names = append(names, regexp.QuoteMeta(flag))
+	}
+	if len(names) > 0 {
+		r.flagPattern = regexp.MustCompile(`(?i)(^|\s)(--?(?:` + strings.Join(names, "|") + `))(=|\s+)(\S+)`)
+	}
+
+	for _, name := range config.CRON_REDACT_ENV {
+		if value := os.Getenv(name); len(value) >= minRedactLength {
+			r.values = append(r.values, value)
+		}
+	}
+
+	return r, nil
+}
+
+// addEnv redacts the values the CRON_REDACT_ENV vars are given in env too,
+// ie: by CRON_ENV_FILE
+func (r *Redactor) addEnv(env []string) {
+	for _, name := range config.CRON_REDACT_ENV {
+		if value, ok := getEnv(env, name); ok && len(value) >= minRedactLength {
+			r.values = append(r.values, value)
+		}
+	}
+}
+
+// Env returns a redacted copy of the KEY=VALUE env vars, the values of the
+// CRON_REDACT_ENV vars and of vars named like secrets are hidden entirely
+func (r *Redactor) Env(env []string) []string {
+	redacted := make([]string, len(env))
+	for i, v := range env {
+		name, value, _ := strings.Cut(v, "=")
+		if value != "" && (slices.Contains(config.CRON_REDACT_ENV, name) || secretEnvPattern.MatchString(name)) {
+			redacted[i] = name + "=" + REDACTED
+		} else {
+			redacted[i] = name + "=" + r.String(value)
+		}
+	}
+	return redacted
+}
+
+// isSecretFlag reports whether arg is one of the redacted flags (ie: --password)
+func (r *Redactor) isSecretFlag(arg string) bool {
+	if !strings.HasPrefix(arg, "-") {
+		return false
+	}
+	return r.flags[strings.ToLower(strings.TrimLeft(arg, "-"))]
+}
+
+// Args returns a redacted copy of the command arguments
+func (r *Redactor) Args(args []string) []string {
+	if r == nil {
+		return args
+	}
+
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		switch {
+		// the value of a secret flag passed as a separate arg: --password hunter2
+		case i > 0 && r.isSecretFlag(args[i-1]) && !strings.Contains(args[i-1], "="):
+			redacted[i] = REDACTED
+		// the value of a secret flag passed inline: --password=hunter2
+		case strings.Contains(arg, "=") && r.isSecretFlag(strings.SplitN(arg, "=", 2)[0]):
+			redacted[i] = strings.SplitN(arg, "=", 2)[0] + "=" + REDACTED
+		default:
+			redacted[i] = r.String(arg)
+		}
+	}
+
+	return redacted
+}
+
+// outputRedactor returns the redactor the output of the command and hooks is
+// passed through before it's passed on, nil unless CRON_REDACT_OUTPUT is set
+func (c *Cron) outputRedactor() *Redactor {
+	if !config.CRON_REDACT_OUTPUT {
+		return nil
+	}
+	return c.redactor
+}
+
+// String redacts secrets in a single string such as a line of output
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+
+	for _, value := range r.values {
+		s = strings.ReplaceAll(s, value, REDACTED)
+	}
+
+	if r.flagPattern != nil {
+		s = r.flagPattern.ReplaceAllString(s, "${1}${2}${3}"+REDACTED)
+	}
+
+	if r.pattern != nil {
+		s = r.redactPattern(s)
+	}
+
+	return s
+}
+
+// redactPattern replaces the matches of CRON_REDACT_REGEX, or only its capture
+// groups if it has any so that `token=(\S+)` keeps the `token=` for context
+func (r *Redactor) redactPattern(s string) string {
+	if r.pattern.NumSubexp() == 0 {
+		return r.pattern.ReplaceAllString(s, REDACTED)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range r.pattern.FindAllStringSubmatchIndex(s, -1) {
+		for group := 1; group*2 < len(match); group++ {
+			start, end := match[group*2], match[group*2+1]
+			if start < last || start == end {
+				// unmatched, empty or nested group
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(REDACTED)
+			last = end
+		}
+	}
+	b.WriteString(s[last:])
+
+	return b.String()
+}
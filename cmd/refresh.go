@@ -0,0 +1,50 @@
+package main
+
+import (
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+	"github.com/devinodaniel/cron-go/cmd/monitor"
+)
+
+// refreshMetrics rewrites the metrics every CRON_METRICS_REFRESH seconds
+// while the command runs, so a long job can be told apart from a dead runner
+func (c *Cron) refreshMetrics(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(config.CRON_METRICS_REFRESH) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.setRefreshMetrics()
+			c.setNotifyMetrics()
+			if err := c.writeMetrics(); err != nil {
+				c.logf("WARNING: %v\n", err)
+			}
+		}
+	}
+}
+
+// setRefreshMetrics sets the elapsed time and the current resource usage of the command
+func (c *Cron) setRefreshMetrics() {
+	now := time.Now()
+
+	monitor.CronElapsedSeconds.WithLabelValues(c.Monitor.Namespace).Set(now.Sub(c.StartTime).Seconds())
+	monitor.CronLastUpdateTimeSeconds.WithLabelValues(c.Monitor.Namespace).Set(float64(now.Unix()))
+	c.setSampleMetrics(c.sampler.sample())
+}
+
+// setFinalRefreshMetrics replaces the in-flight metrics once the command exited
+func (c *Cron) setFinalRefreshMetrics() {
+	monitor.CronElapsedSeconds.WithLabelValues(c.Monitor.Namespace).Set(c.Duration.Seconds())
+	monitor.CronLastUpdateTimeSeconds.WithLabelValues(c.Monitor.Namespace).Set(float64(c.EndTime.Unix()))
+
+	// nothing is running anymore, don't leave the last sample behind
+	c.deleteSampleMetrics()
+	if c.processState != nil {
+		cpu := c.processState.UserTime() + c.processState.SystemTime()
+		monitor.CronCPUSeconds.WithLabelValues(c.Monitor.Namespace).Set(cpu.Seconds())
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestMetricsRefresh(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS_REFRESH, 1)
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE, "metrics_refresh")
+
+	metricsFile := filepath.Join(config.CRON_METRICS_DIR, "cron_metrics_refresh_metrics.prom")
+
+	cron, _ := New([]string{"sleep", "2"})
+	done := make(chan struct{})
+	go func() {
+		cron.Run()
+		close(done)
+	}()
+
+	// after the first refresh, while the command is still running
+	time.Sleep(1500 * time.Millisecond)
+	data, _ := os.ReadFile(metricsFile)
+	for _, metric := range []string{
+		`cron_status{code="4",namespace="metrics_refresh",status="RUNNING"} 1`,
+		`cron_elapsed_seconds{namespace="metrics_refresh"} 1`,
+		`cron_last_update_time_seconds{namespace="metrics_refresh"}`,
+		`cron_cpu_seconds{namespace="metrics_refresh"}`,
+		`cron_memory_rss_bytes{namespace="metrics_refresh"}`,
+	} {
+		if !strings.Contains(string(data), metric) {
+			t.Errorf("Expected in-flight metrics file to contain %s", metric)
+		}
+	}
+
+	<-done
+	data, _ = os.ReadFile(metricsFile)
+	if !strings.Contains(string(data), `cron_elapsed_seconds{namespace="metrics_refresh"} 2`) {
+		t.Errorf("Expected the final elapsed time, got %s", data)
+	}
+	if strings.Contains(string(data), "cron_memory_rss_bytes") {
+		t.Errorf("Expected no resident memory once the command exited")
+	}
+}
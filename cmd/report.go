@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+	"golang.org/x/sys/unix"
+)
+
+// REPORT DESTINATIONS
+const (
+	CRON_REPORT_FILE   = "file"   // cron_<namespace>_report.json next to the .prom file
+	CRON_REPORT_STDOUT = "stdout" // a single line of JSON on stdout
+	CRON_REPORT_FD     = "fd:"    // fd:<n> an already open file descriptor
+)
+
+// Report is the JSON summary of a single run for downstream tooling
+type Report struct {
+	RunID          string          `json:"runId"`
+	Namespace      string          `json:"namespace"`
+	Host           string          `json:"host"`
+	Args           []string        `json:"args"`
+	StartTime      time.Time       `json:"startTime"`
+	EndTime        time.Time       `json:"endTime"`
+	DurationMs     int64           `json:"durationMs"`
+	TimeoutSeconds int             `json:"timeoutSeconds"`
+	Status         StatusCode      `json:"status"`
+	Exit           ExitCode        `json:"exit"`
+	Signal         string          `json:"signal,omitempty"`
+	CoreDumped     bool            `json:"coreDumped"`
+	LaunchFailure  string          `json:"launchFailure,omitempty"`
+	Error          string          `json:"error,omitempty"` // why the runner refused to start the command
+	OutputCheck    *OutputCheck    `json:"outputCheck,omitempty"`
+	Notify         *NotifyState    `json:"notify,omitempty"`
+	LastOutput     string          `json:"lastOutput,omitempty"` // last line of output of a hung command
+	Usage          *ResourceUsage  `json:"usage,omitempty"`
+	Peaks          *ResourceSample `json:"peaks,omitempty"`
+	Cgroup         *CgroupUsage    `json:"cgroup,omitempty"`
+	User           string          `json:"user,omitempty"`
+	Scratch        *ScratchDir     `json:"scratch,omitempty"`
+	Precondition   *Precondition   `json:"precondition,omitempty"`
+	Hooks          []HookRun       `json:"hooks,omitempty"`
+	Attempt        int             `json:"attempt"`
+	Dryrun         bool            `json:"dryrun"`
+	Log            string          `json:"log,omitempty"` // file the runner's output is appended to, if any
+}
+
+// report builds the JSON report of the run
+func (c *Cron) report() Report {
+	host, _ := os.Hostname()
+
+	var setupErr string
+	if c.setupErr != nil {
+		setupErr = c.redactor.String(c.setupErr.Error())
+	}
+
+	return Report{
+		RunID:          c.RunID,
+		Namespace:      c.Monitor.Namespace,
+		Host:           host,
+		Args:           c.redactor.Args(c.Args),
+		StartTime:      c.StartTime,
+		EndTime:        c.EndTime,
+		DurationMs:     c.Duration.Milliseconds(),
+		TimeoutSeconds: config.CRON_TIMEOUT,
+		Status:         StatusCode{c.StatusCode, c.GetStatusCodeName()},
+		Exit:           ExitCode{c.ExitCode, c.GetExitCodeName()},
+		Signal:         c.Signal,
+		CoreDumped:     c.CoreDumped,
+		LaunchFailure:  c.LaunchFailure,
+		Error:          setupErr,
+		OutputCheck:    c.OutputCheck,
+		Notify:         c.Notify,
+		LastOutput:     c.LastOutput,
+		Usage:          c.Usage,
+		Peaks:          c.Peaks,
+		Cgroup:         c.Cgroup,
+		User:           c.User,
+		Scratch:        c.Scratch,
+		Precondition:   c.Precondition,
+		Hooks:          c.Hooks,
+		Attempt:        c.Attempt,
+		Dryrun:         config.CRON_DRYRUN,
+		Log:            logLocation(),
+	}
+}
+
+// writeReport writes the JSON report to the CRON_REPORT destination
+func (c *Cron) writeReport() error {
+	data, err := json.Marshal(c.report())
+	if err != nil {
+		return fmt.Errorf("error encoding report: %v", err)
+	}
+	data = append(data, '\n')
+
+	switch dest := config.CRON_REPORT; {
+	case dest == CRON_REPORT_FILE:
+		return writeFileAtomic(filepath.Join(config.CRON_METRICS_DIR, fmt.Sprintf("cron_%s_report.json", c.Monitor.Namespace)), data)
+	case dest == CRON_REPORT_STDOUT:
+		_, err = os.Stdout.Write(data)
+		return err
+	case strings.HasPrefix(dest, CRON_REPORT_FD):
+		fd, err := strconv.Atoi(strings.TrimPrefix(dest, CRON_REPORT_FD))
+		if err != nil || fd < 0 {
+			return fmt.Errorf("invalid CRON_REPORT file descriptor: %s", dest)
+		}
+		// written to directly, the fd isn't the runner's to close
+		for len(data) > 0 {
+			n, err := unix.Write(fd, data)
+			if err == unix.EINTR {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("error writing report to %s: %v", dest, err)
+			}
+			data = data[n:]
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid CRON_REPORT destination: %s", dest)
+	}
+}
+
+// logLocation returns the file the runner's stdout is redirected to
+// ie: `cron-runner backup.sh >> /var/log/backup.log 2>&1` returns /var/log/backup.log
+func logLocation() string {
+	target, err := os.Readlink("/proc/self/fd/1")
+	if err != nil {
+		return ""
+	}
+
+	if info, err := os.Stat(target); err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+
+	return target
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// readReport parses the report written for the namespace
+func readReport(t *testing.T, namespace string) Report {
+	var report Report
+
+	data, err := os.ReadFile(filepath.Join(config.CRON_METRICS_DIR, "cron_"+namespace+"_report.json"))
+	if err != nil {
+		t.Fatalf("Expected report to be written, got %v", err)
+	}
+
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Expected report to be valid JSON, got %v", err)
+	}
+
+	return report
+}
+
+func TestReportFile(t *testing.T) {
+	setConfig(t, &config.CRON_REPORT, CRON_REPORT_FILE)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_NAMESPACE, "report")
+
+	cron, _ := New([]string{"sh", "-c", "exit 127", "--token=abc123"})
+	if err := cron.Run(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	report := readReport(t, "report")
+
+	if report.Status.Code != CRON_STATUS_FAIL || report.Status.Name != "FAIL" {
+		t.Errorf("Expected status %d (FAIL), got %d (%s)", CRON_STATUS_FAIL, report.Status.Code, report.Status.Name)
+	}
+
+	if report.Exit.Code != CRON_EXITCODE_EXEC_NOT_FOUND || report.Exit.Name != "EXEC_NOT_FOUND" {
+		t.Errorf("Expected exit %d (EXEC_NOT_FOUND), got %d (%s)", CRON_EXITCODE_EXEC_NOT_FOUND, report.Exit.Code, report.Exit.Name)
+	}
+
+	if report.EndTime.Before(report.StartTime) || report.Attempt != 1 {
+		t.Errorf("Expected a finished first attempt, got %+v", report)
+	}
+
+	if report.Args[3] != "--token="+REDACTED {
+		t.Errorf("Expected args to be redacted, got %s", report.Args[3])
+	}
+}
+
+func TestReportFd(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	setConfig(t, &config.CRON_REPORT, fmt.Sprintf("fd:%d", w.Fd()))
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_METRICS, false)
+
+	// the fd is still open for the next run
+	for i := 0; i < 2; i++ {
+		cron, _ := New([]string{"true"})
+		if err := cron.Run(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		runtime.GC()
+	}
+
+	decoder := json.NewDecoder(r)
+	for i := 0; i < 2; i++ {
+		var report Report
+		if err := decoder.Decode(&report); err != nil {
+			t.Fatalf("Expected a report per run, got %v", err)
+		}
+		if report.Status.Code != CRON_STATUS_SUCCESS {
+			t.Errorf("Expected status %d, got %d", CRON_STATUS_SUCCESS, report.Status.Code)
+		}
+	}
+}
+
+func TestReportInvalidDestination(t *testing.T) {
+	setConfig(t, &config.CRON_REPORT, "fd:stdout")
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"true"})
+	if err := cron.Run(); err == nil {
+		t.Errorf("Expected an error for an invalid CRON_REPORT, got nil")
+	}
+}
+
+func TestReportNamespaceCollision(t *testing.T) {
+	setConfig(t, &config.CRON_STATE_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE_COLLISION, CRON_COLLISION_FAIL)
+	setConfig(t, &config.CRON_NAMESPACE, "shared")
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+
+	first, _ := New([]string{"true"})
+	first.Run()
+
+	// the runner refused to start the second one, downstream still needs to know
+	out, err := os.Create(filepath.Join(t.TempDir(), "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	setConfig(t, &config.CRON_REPORT, fmt.Sprintf("%s%d", CRON_REPORT_FD, out.Fd()))
+
+	second, _ := New([]string{"false"})
+	if err := second.Run(); err == nil {
+		t.Errorf("Expected a namespace collision error, got nil")
+	}
+
+	var report Report
+	data, _ := os.ReadFile(out.Name())
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Expected a report, got %v", err)
+	}
+	if report.Status.Code != CRON_STATUS_FAIL || report.Error == "" {
+		t.Errorf("Expected a failed run and why, got %+v", report)
+	}
+}
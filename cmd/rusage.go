@@ -0,0 +1,63 @@
+package main
+
+import (
+	"syscall"
+
+	"github.com/devinodaniel/cron-go/cmd/monitor"
+)
+
+// ResourceUsage is what the command and the processes it waited for used,
+// as reported by the kernel when it exited
+type ResourceUsage struct {
+	UserCPUSeconds         float64 `json:"userCpuSeconds"`
+	SystemCPUSeconds       float64 `json:"systemCpuSeconds"`
+	MaxRSSBytes            int64   `json:"maxRssBytes"`
+	BlockInputOps          int64   `json:"blockInputOps"`
+	BlockOutputOps         int64   `json:"blockOutputOps"`
+	VoluntaryCtxSwitches   int64   `json:"voluntaryContextSwitches"`
+	InvoluntaryCtxSwitches int64   `json:"involuntaryContextSwitches"`
+	MajorPageFaults        int64   `json:"majorPageFaults"`
+	MinorPageFaults        int64   `json:"minorPageFaults"`
+}
+
+// resourceUsage returns the rusage of the command, nil if it never ran
+func (c *Cron) resourceUsage() *ResourceUsage {
+	if c.processState == nil {
+		return nil
+	}
+
+	rusage, ok := c.processState.SysUsage().(*syscall.Rusage)
+	if !ok || rusage == nil {
+		return nil
+	}
+
+	return &ResourceUsage{
+		UserCPUSeconds:         c.processState.UserTime().Seconds(),
+		SystemCPUSeconds:       c.processState.SystemTime().Seconds(),
+		MaxRSSBytes:            int64(rusage.Maxrss) * 1024, // kilobytes on linux
+		BlockInputOps:          int64(rusage.Inblock),
+		BlockOutputOps:         int64(rusage.Oublock),
+		VoluntaryCtxSwitches:   int64(rusage.Nvcsw),
+		InvoluntaryCtxSwitches: int64(rusage.Nivcsw),
+		MajorPageFaults:        int64(rusage.Majflt),
+		MinorPageFaults:        int64(rusage.Minflt),
+	}
+}
+
+// setUsageMetrics sets the resource usage metrics of the run
+func (c *Cron) setUsageMetrics() {
+	if c.Usage == nil {
+		return
+	}
+
+	ns := c.Monitor.Namespace
+	monitor.CronCPUUserSeconds.WithLabelValues(ns).Set(c.Usage.UserCPUSeconds)
+	monitor.CronCPUSystemSeconds.WithLabelValues(ns).Set(c.Usage.SystemCPUSeconds)
+	monitor.CronMaxRSSBytes.WithLabelValues(ns).Set(float64(c.Usage.MaxRSSBytes))
+	monitor.CronBlockIOOperations.WithLabelValues(ns, "in").Set(float64(c.Usage.BlockInputOps))
+	monitor.CronBlockIOOperations.WithLabelValues(ns, "out").Set(float64(c.Usage.BlockOutputOps))
+	monitor.CronContextSwitches.WithLabelValues(ns, "voluntary").Set(float64(c.Usage.VoluntaryCtxSwitches))
+	monitor.CronContextSwitches.WithLabelValues(ns, "involuntary").Set(float64(c.Usage.InvoluntaryCtxSwitches))
+	monitor.CronPageFaults.WithLabelValues(ns, "major").Set(float64(c.Usage.MajorPageFaults))
+	monitor.CronPageFaults.WithLabelValues(ns, "minor").Set(float64(c.Usage.MinorPageFaults))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestResourceUsage(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE, "resource_usage")
+
+	// burn a little cpu so there's something to measure
+	cron, _ := New([]string{"sh", "-c", "i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done"})
+	cron.Run()
+
+	if cron.Usage == nil {
+		t.Fatal("Expected the resource usage to be set")
+	}
+	if cron.Usage.UserCPUSeconds+cron.Usage.SystemCPUSeconds <= 0 {
+		t.Errorf("Expected some cpu time, got %+v", cron.Usage)
+	}
+	if cron.Usage.MaxRSSBytes <= 0 || cron.Usage.MinorPageFaults <= 0 {
+		t.Errorf("Expected the peak memory and page faults, got %+v", cron.Usage)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(config.CRON_METRICS_DIR, "cron_resource_usage_metrics.prom"))
+	for _, metric := range []string{
+		`cron_cpu_user_seconds{namespace="resource_usage"}`,
+		`cron_cpu_system_seconds{namespace="resource_usage"}`,
+		`cron_max_rss_bytes{namespace="resource_usage"}`,
+		`cron_block_io_operations{direction="in",namespace="resource_usage"}`,
+		`cron_block_io_operations{direction="out",namespace="resource_usage"}`,
+		`cron_context_switches{namespace="resource_usage",type="voluntary"}`,
+		`cron_context_switches{namespace="resource_usage",type="involuntary"}`,
+		`cron_page_faults{namespace="resource_usage",type="major"}`,
+		`cron_page_faults{namespace="resource_usage",type="minor"}`,
+	} {
+		if !strings.Contains(string(data), metric) {
+			t.Errorf("Expected metrics file to contain %s", metric)
+		}
+	}
+}
+
+func TestResourceUsageLaunchFailure(t *testing.T) {
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"/does/not/exist"})
+	cron.Run()
+
+	if cron.Usage != nil {
+		t.Errorf("Expected no resource usage for a command that never started, got %+v", cron.Usage)
+	}
+}
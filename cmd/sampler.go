@@ -0,0 +1,141 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+	"github.com/devinodaniel/cron-go/cmd/monitor"
+)
+
+// ResourceSample is what the command and all the processes it started use at
+// a point in time, read from /proc
+type ResourceSample struct {
+	Processes  int     `json:"processes"`
+	Threads    int     `json:"threads"`
+	CPUSeconds float64 `json:"cpuSeconds"`
+	CPUPercent float64 `json:"cpuPercent"` // since the previous sample, 100 is one full core
+	RSSBytes   int64   `json:"rssBytes"`
+	OpenFDs    int     `json:"openFds"`
+	ReadBytes  uint64  `json:"readBytes"`  // from storage, /proc/<pid>/io read_bytes
+	WriteBytes uint64  `json:"writeBytes"` // to storage, /proc/<pid>/io write_bytes
+}
+
+// sampler samples the process tree of the command while it runs and keeps
+// the latest sample and the peak of every value
+type sampler struct {
+	pid int
+
+	mu      sync.Mutex
+	latest  ResourceSample
+	peak    ResourceSample
+	samples int
+	last    time.Time // when the previous sample was taken
+}
+
+func newSampler(pid int, started time.Time) *sampler {
+	return &sampler{
+		pid:  pid,
+		last: started,
+	}
+}
+
+// run samples every CRON_SAMPLE_INTERVAL seconds until done is closed
+func (s *sampler) run(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Duration(config.CRON_SAMPLE_INTERVAL) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.sample()
+		}
+	}
+}
+
+// sample takes a sample now and returns it
+func (s *sampler) sample() ResourceSample {
+	sample := treeSample(s.pid)
+	now := time.Now()
+
+	// the command already exited, there was nothing to sample
+	if sample.Processes == 0 {
+		return sample
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// cpu time leaves the tree with the processes that exit, don't go negative
+	if elapsed := now.Sub(s.last).Seconds(); elapsed > 0 {
+		sample.CPUPercent = max(sample.CPUSeconds-s.latest.CPUSeconds, 0) / elapsed * 100
+	}
+
+	s.latest = sample
+	s.last = now
+	s.samples++
+
+	s.peak.Processes = max(s.peak.Processes, sample.Processes)
+	s.peak.Threads = max(s.peak.Threads, sample.Threads)
+	s.peak.CPUSeconds = max(s.peak.CPUSeconds, sample.CPUSeconds)
+	s.peak.CPUPercent = max(s.peak.CPUPercent, sample.CPUPercent)
+	s.peak.RSSBytes = max(s.peak.RSSBytes, sample.RSSBytes)
+	s.peak.OpenFDs = max(s.peak.OpenFDs, sample.OpenFDs)
+	s.peak.ReadBytes = max(s.peak.ReadBytes, sample.ReadBytes)
+	s.peak.WriteBytes = max(s.peak.WriteBytes, sample.WriteBytes)
+
+	return sample
+}
+
+// peaks returns the peak of every value sampled, nil if nothing was sampled
+func (s *sampler) peaks() *ResourceSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.samples == 0 {
+		return nil
+	}
+
+	peak := s.peak
+	return &peak
+}
+
+// setSampleMetrics sets the current resource usage metrics from a sample
+func (c *Cron) setSampleMetrics(sample ResourceSample) {
+	ns := c.Monitor.Namespace
+	monitor.CronCPUSeconds.WithLabelValues(ns).Set(sample.CPUSeconds)
+	monitor.CronCPUPercent.WithLabelValues(ns).Set(sample.CPUPercent)
+	monitor.CronMemoryRSSBytes.WithLabelValues(ns).Set(float64(sample.RSSBytes))
+	monitor.CronProcesses.WithLabelValues(ns).Set(float64(sample.Processes))
+	monitor.CronThreads.WithLabelValues(ns).Set(float64(sample.Threads))
+	monitor.CronOpenFDs.WithLabelValues(ns).Set(float64(sample.OpenFDs))
+	monitor.CronIOBytes.WithLabelValues(ns, "read").Set(float64(sample.ReadBytes))
+	monitor.CronIOBytes.WithLabelValues(ns, "write").Set(float64(sample.WriteBytes))
+}
+
+// setPeakMetrics sets the peak resource usage metrics of the run
+func (c *Cron) setPeakMetrics() {
+	if c.Peaks == nil {
+		return
+	}
+
+	ns := c.Monitor.Namespace
+	monitor.CronPeakCPUPercent.WithLabelValues(ns).Set(c.Peaks.CPUPercent)
+	monitor.CronPeakMemoryRSSBytes.WithLabelValues(ns).Set(float64(c.Peaks.RSSBytes))
+	monitor.CronPeakProcesses.WithLabelValues(ns).Set(float64(c.Peaks.Processes))
+	monitor.CronPeakThreads.WithLabelValues(ns).Set(float64(c.Peaks.Threads))
+	monitor.CronPeakOpenFDs.WithLabelValues(ns).Set(float64(c.Peaks.OpenFDs))
+}
+
+// deleteSampleMetrics removes the current resource usage metrics that don't
+// mean anything once the command exited
+func (c *Cron) deleteSampleMetrics() {
+	ns := c.Monitor.Namespace
+	monitor.CronCPUPercent.DeleteLabelValues(ns)
+	monitor.CronMemoryRSSBytes.DeleteLabelValues(ns)
+	monitor.CronProcesses.DeleteLabelValues(ns)
+	monitor.CronThreads.DeleteLabelValues(ns)
+	monitor.CronOpenFDs.DeleteLabelValues(ns)
+}
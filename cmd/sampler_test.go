@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestSamplerPeaks(t *testing.T) {
+	setConfig(t, &config.CRON_SAMPLE_INTERVAL, 1)
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE, "sampler_peaks")
+
+	// the shell and both its children are sampled
+	cron, _ := New([]string{"sh", "-c", "sleep 1.5 & sleep 1.5 & wait"})
+	cron.Run()
+
+	if cron.Peaks == nil {
+		t.Fatal("Expected peaks to be sampled")
+	}
+	if cron.Peaks.Processes != 3 || cron.Peaks.Threads < 3 {
+		t.Errorf("Expected 3 processes and at least 3 threads, got %+v", cron.Peaks)
+	}
+	if cron.Peaks.RSSBytes <= 0 || cron.Peaks.OpenFDs <= 0 {
+		t.Errorf("Expected resident memory and open fds, got %+v", cron.Peaks)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(config.CRON_METRICS_DIR, "cron_sampler_peaks_metrics.prom"))
+	for _, metric := range []string{
+		`cron_peak_processes{namespace="sampler_peaks"} 3`,
+		`cron_peak_memory_rss_bytes{namespace="sampler_peaks"}`,
+		`cron_peak_cpu_percent{namespace="sampler_peaks"}`,
+		`cron_peak_threads{namespace="sampler_peaks"}`,
+		`cron_peak_open_fds{namespace="sampler_peaks"}`,
+	} {
+		if !strings.Contains(string(data), metric) {
+			t.Errorf("Expected metrics file to contain %s", metric)
+		}
+	}
+	if strings.Contains(string(data), `cron_processes{namespace="sampler_peaks"}`) {
+		t.Errorf("Expected no current process count once the command exited")
+	}
+}
+
+func TestSamplerDisabled(t *testing.T) {
+	setConfig(t, &config.CRON_SAMPLE_INTERVAL, 0)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"sleep", "1.5"})
+	cron.Run()
+
+	if cron.Peaks != nil {
+		t.Errorf("Expected no peaks with sampling disabled, got %+v", cron.Peaks)
+	}
+}
+
+func TestTreeSample(t *testing.T) {
+	sample := treeSample(os.Getpid())
+
+	if sample.Processes < 1 || sample.Threads < 1 || sample.OpenFDs < 3 || sample.RSSBytes <= 0 || sample.CPUSeconds <= 0 {
+		t.Errorf("Expected the test process itself to be sampled, got %+v", sample)
+	}
+}
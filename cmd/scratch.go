@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+	"github.com/devinodaniel/cron-go/cmd/monitor"
+)
+
+// errScratchFull is the cause of the cancellation when the scratch directory
+// grew past CRON_SCRATCH_MAX
+var errScratchFull = errors.New("scratch directory full")
+
+// scratchPrefix starts the name of every scratch directory, the retention
+// sweep only ever removes directories named like it
+const scratchPrefix = "cron-scratch-"
+
+// scratchKeptMarker is written into a scratch directory once its run failed,
+// the retention sweep only removes marked directories so that it never touches
+// one a run is still using, however long it has been running
+const scratchKeptMarker = ".cron-kept"
+
+// how often the size of the scratch directory is checked while the command runs
+var scratchCheckInterval = 5 * time.Second
+
+// ScratchDir is the private temp directory of a run
+type ScratchDir struct {
+	Path  string `json:"path,omitempty"` // only set when it was kept
+	Bytes int64  `json:"bytes"`          // size once the command exited
+	Kept  bool   `json:"kept"`
+}
+
+// scratchEnabled reports whether the run gets a scratch directory
+func scratchEnabled() bool {
+	return config.CRON_SCRATCH || config.CRON_SCRATCH_MAX != ""
+}
+
+// scratchMax parses CRON_SCRATCH_MAX, 0 if unlimited
+func scratchMax() (int64, error) {
+	if config.CRON_SCRATCH_MAX == "" {
+		return 0, nil
+	}
+
+	size, err := parseBytes(config.CRON_SCRATCH_MAX)
+	if err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid CRON_SCRATCH_MAX: %s", config.CRON_SCRATCH_MAX)
+	}
+	return size, nil
+}
+
+// parseBytes parses a size in bytes with an optional K, M, G or T suffix
+// (powers of 1024) the same way memory.max does, ie: 512M
+func parseBytes(value string) (int64, error) {
+	if value == "" {
+		return 0, errors.New("empty size")
+	}
+
+	units := map[string]int64{"K": 1 << 10, "M": 1 << 20, "G": 1 << 30, "T": 1 << 40}
+
+	multiplier := int64(1)
+	if unit, ok := units[strings.ToUpper(value[len(value)-1:])]; ok && len(value) > 1 {
+		multiplier = unit
+		value = value[:len(value)-1]
+	}
+
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return size * multiplier, nil
+}
+
+// scratchParent returns where the scratch directories are created
+func scratchParent() string {
+	if config.CRON_SCRATCH_DIR != "" {
+		return config.CRON_SCRATCH_DIR
+	}
+	return os.TempDir()
+}
+
+// createScratch creates the private scratch directory of the run, only
+// accessible to the user the command runs as
+func (c *Cron) createScratch() (string, error) {
+	path := filepath.Join(scratchParent(), fmt.Sprintf("%s%.100s-%s", scratchPrefix, c.Monitor.Namespace, c.RunID))
+
+	if err := os.Mkdir(path, 0700); err != nil {
+		return "", err
+	}
+	if err := c.runAs.chown(path); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// sweepScratch removes the scratch directories kept by failed runs more than
+// CRON_SCRATCH_RETENTION_DAYS ago, whichever job they belong to
+func sweepScratch() {
+	if config.CRON_SCRATCH_RETENTION_DAYS <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -config.CRON_SCRATCH_RETENTION_DAYS)
+
+	parent := scratchParent()
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), scratchPrefix) {
+			continue
+		}
+		path := filepath.Join(parent, entry.Name())
+		if info, err := os.Lstat(filepath.Join(path, scratchKeptMarker)); err == nil && info.ModTime().Before(cutoff) {
+			// another runner's, best effort
+			os.RemoveAll(path)
+		}
+	}
+}
+
+// dirSize returns the apparent size of the files under path
+func dirSize(path string) int64 {
+	var size int64
+
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// removed while walking, or unreadable
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+
+	return size
+}
+
+// scratchWatch calls expire once the scratch directory is larger than max
+func scratchWatch(path string, max int64, done <-chan struct{}, expire func()) {
+	ticker := time.NewTicker(scratchCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if dirSize(path) > max {
+				expire()
+				return
+			}
+		}
+	}
+}
+
+// applyScratch fails a run that filled its scratch directory past the limit
+// and removes the directory unless the run failed
+func (c *Cron) applyScratch() {
+	if c.scratch == "" {
+		return
+	}
+
+	c.Scratch = &ScratchDir{Bytes: dirSize(c.scratch)}
+
+	// written right before the command exited, between two checks
+	max, _ := scratchMax()
+	succeeded := c.StatusCode == CRON_STATUS_SUCCESS || c.StatusCode == CRON_STATUS_WARNING
+	if max > 0 && c.Scratch.Bytes > max && succeeded {
+		c.logf("ERROR: scratch directory larger than CRON_SCRATCH_MAX %s\n", config.CRON_SCRATCH_MAX)
+		c.ExitCode, c.StatusCode = CRON_EXITCODE_SCRATCH_FULL, CRON_STATUS_FAIL
+		succeeded = false
+	}
+
+	// kept for debugging
+	if !succeeded {
+		c.Scratch.Path = c.scratch
+		c.Scratch.Kept = true
+		c.logf("WARNING: run failed, kept the scratch directory %s\n", c.scratch)
+		if err := os.WriteFile(filepath.Join(c.scratch, scratchKeptMarker), nil, 0600); err != nil {
+			c.logf("WARNING: scratch directory won't be swept: %v\n", err)
+		}
+		return
+	}
+
+	if err := os.RemoveAll(c.scratch); err != nil {
+		c.logf("WARNING: scratch directory left behind: %v\n", err)
+	}
+}
+
+// setScratchMetrics sets the size of the scratch directory
+func (c *Cron) setScratchMetrics() {
+	if c.Scratch == nil {
+		return
+	}
+
+	monitor.CronScratchBytes.WithLabelValues(c.Monitor.Namespace).Set(float64(c.Scratch.Bytes))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// runScratch runs the script and returns the scratch directory it was given
+func runScratch(t *testing.T, script string) (*Cron, string) {
+	setConfig(t, &config.CRON_METRICS, false)
+
+	out := filepath.Join(t.TempDir(), "scratch")
+	cron, err := New([]string{"sh", "-c", `test "$TMPDIR" = "$CRON_WORKDIR" && echo -n "$TMPDIR" > ` + out + "; " + script})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cron.Run()
+
+	data, _ := os.ReadFile(out)
+	return cron, string(data)
+}
+
+func TestRunScratchRemoved(t *testing.T) {
+	parent := t.TempDir()
+	setConfig(t, &config.CRON_SCRATCH, true)
+	setConfig(t, &config.CRON_SCRATCH_DIR, parent)
+
+	cron, dir := runScratch(t, `echo data > "$TMPDIR/intermediate"`)
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Fatalf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+	if filepath.Dir(dir) != parent || !strings.HasPrefix(filepath.Base(dir), scratchPrefix) {
+		t.Fatalf("Expected a scratch directory in %s, got %q", parent, dir)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected the scratch directory to be removed, got %v", err)
+	}
+	if cron.Scratch == nil || cron.Scratch.Kept || cron.Scratch.Path != "" || cron.Scratch.Bytes != 5 {
+		t.Errorf("Expected 5 bytes and no path, got %+v", cron.Scratch)
+	}
+}
+
+func TestRunScratchKeptOnFailure(t *testing.T) {
+	setConfig(t, &config.CRON_SCRATCH, true)
+	setConfig(t, &config.CRON_SCRATCH_DIR, t.TempDir())
+
+	cron, dir := runScratch(t, `echo data > "$TMPDIR/intermediate"; exit 1`)
+
+	if cron.StatusCode != CRON_STATUS_FAIL {
+		t.Fatalf("Expected status code %d, got %d", CRON_STATUS_FAIL, cron.StatusCode)
+	}
+	if cron.Scratch == nil || !cron.Scratch.Kept || cron.Scratch.Path != dir {
+		t.Fatalf("Expected the scratch directory %s to be kept, got %+v", dir, cron.Scratch)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "intermediate")); string(data) != "data\n" {
+		t.Errorf("Expected the files to be kept, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, scratchKeptMarker)); err != nil {
+		t.Errorf("Expected the directory to be marked as kept, got %v", err)
+	}
+	if info, _ := os.Stat(dir); info == nil || info.Mode().Perm() != 0700 {
+		t.Errorf("Expected a private directory, got %v", info)
+	}
+}
+
+func TestRunScratchFullAtExit(t *testing.T) {
+	setConfig(t, &config.CRON_SCRATCH, true)
+	setConfig(t, &config.CRON_SCRATCH_DIR, t.TempDir())
+	setConfig(t, &config.CRON_SCRATCH_MAX, "1K")
+
+	// exits before the size is checked
+	cron, _ := runScratch(t, `head -c 2048 /dev/zero > "$TMPDIR/big"`)
+
+	if cron.ExitCode != CRON_EXITCODE_SCRATCH_FULL || cron.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected exit code %d and status code %d, got %d and %d", CRON_EXITCODE_SCRATCH_FULL, CRON_STATUS_FAIL, cron.ExitCode, cron.StatusCode)
+	}
+	if cron.Scratch == nil || !cron.Scratch.Kept || cron.Scratch.Bytes != 2048 {
+		t.Errorf("Expected the full scratch directory to be kept, got %+v", cron.Scratch)
+	}
+}
+
+func TestRunScratchFullKilled(t *testing.T) {
+	setConfig(t, &config.CRON_SCRATCH, true)
+	setConfig(t, &config.CRON_SCRATCH_DIR, t.TempDir())
+	setConfig(t, &config.CRON_SCRATCH_MAX, "1K")
+	setConfig(t, &scratchCheckInterval, 100*time.Millisecond)
+
+	cron, _ := runScratch(t, `head -c 2048 /dev/zero > "$TMPDIR/big"; sleep 10`)
+
+	if cron.ExitCode != CRON_EXITCODE_SCRATCH_FULL || cron.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected exit code %d and status code %d, got %d and %d", CRON_EXITCODE_SCRATCH_FULL, CRON_STATUS_FAIL, cron.ExitCode, cron.StatusCode)
+	}
+	if cron.Duration > 5*time.Second {
+		t.Errorf("Expected the command to be killed right away, took %v", cron.Duration)
+	}
+}
+
+func TestSweepScratch(t *testing.T) {
+	parent := t.TempDir()
+	setConfig(t, &config.CRON_SCRATCH, true)
+	setConfig(t, &config.CRON_SCRATCH_DIR, parent)
+	setConfig(t, &config.CRON_SCRATCH_RETENTION_DAYS, 7)
+
+	// a run that has been going for longer than the retention isn't kept yet
+	old := time.Now().AddDate(0, 0, -8)
+	for _, name := range []string{scratchPrefix + "old", scratchPrefix + "new", scratchPrefix + "running", "not-ours"} {
+		os.Mkdir(filepath.Join(parent, name), 0700)
+		os.WriteFile(filepath.Join(parent, name, "file"), nil, 0600)
+		if name != scratchPrefix+"running" {
+			os.WriteFile(filepath.Join(parent, name, scratchKeptMarker), nil, 0600)
+		}
+	}
+	for _, path := range []string{
+		filepath.Join(parent, scratchPrefix+"old", scratchKeptMarker),
+		filepath.Join(parent, scratchPrefix+"running"),
+		filepath.Join(parent, "not-ours", scratchKeptMarker),
+	} {
+		os.Chtimes(path, old, old)
+	}
+
+	sweepScratch()
+
+	if _, err := os.Stat(filepath.Join(parent, scratchPrefix+"old")); !os.IsNotExist(err) {
+		t.Errorf("Expected the expired scratch directory to be removed, got %v", err)
+	}
+	for _, name := range []string{scratchPrefix + "new", scratchPrefix + "running", "not-ours"} {
+		if _, err := os.Stat(filepath.Join(parent, name)); err != nil {
+			t.Errorf("Expected %s to be left alone, got %v", name, err)
+		}
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	for value, expected := range map[string]int64{
+		"512": 512,
+		"1K":  1024,
+		"10m": 10 << 20,
+		"2G":  2 << 30,
+		"1T":  1 << 40,
+	} {
+		if size, err := parseBytes(value); err != nil || size != expected {
+			t.Errorf("Expected %d for %s, got %d %v", expected, value, size, err)
+		}
+	}
+
+	for _, value := range []string{"K", "1.5G", "10GB", "-1K"} {
+		setConfig(t, &config.CRON_SCRATCH_MAX, value)
+		if _, err := scratchMax(); err == nil {
+			t.Errorf("Expected an error for %s, got nil", value)
+		}
+	}
+	setConfig(t, &config.CRON_SCRATCH_MAX, "")
+}
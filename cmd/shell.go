@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// CRON_SHELL_FLAG runs the command string through CRON_SHELL
+const CRON_SHELL_FLAG = "--shell"
+
+// the shell used by --shell when CRON_SHELL isn't set, sh doesn't always
+// support pipefail
+const defaultShell = "/bin/bash"
+
+// shellArgs returns the args to run the command string through the shell with
+// in shell mode, args unchanged otherwise
+func shellArgs(args []string) ([]string, string, error) {
+	shell := config.CRON_SHELL
+	if len(args) > 0 && args[0] == CRON_SHELL_FLAG {
+		args = args[1:]
+		if shell == "" {
+			shell = defaultShell
+		}
+	}
+	if shell == "" {
+		return args, "", nil
+	}
+
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		return nil, "", errors.New("shell mode takes a single command string, quote it")
+	}
+
+	shellArgs := append([]string{shell}, strings.Fields(config.CRON_SHELL_OPTIONS)...)
+	return append(shellArgs, "-c", args[0]), args[0], nil
+}
+
+// firstCommand returns the words of the first simple command in a command
+// string: up to the first pipe, list or redirection operator and without
+// leading VAR=value assignments. Quotes and backslashes are removed, nothing
+// is expanded.
+func firstCommand(command string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	quote := byte(0)
+
+	flush := func() {
+		if !inWord {
+			return
+		}
+		// VAR=value before the command itself, or a group
+		if len(words) == 0 && (isAssignment(word.String()) || word.String() == "{") {
+			word.Reset()
+			inWord = false
+			return
+		}
+		words = append(words, word.String())
+		word.Reset()
+		inWord = false
+	}
+
+	for i := 0; i < len(command); i++ {
+		ch := command[i]
+
+		switch {
+		case quote == '\'':
+			if ch == '\'' {
+				quote = 0
+			} else {
+				word.WriteByte(ch)
+			}
+		case quote == '"':
+			switch {
+			case ch == '"':
+				quote = 0
+			case ch == '\\' && i+1 < len(command) && strings.IndexByte("$`\"\\", command[i+1]) >= 0:
+				i++
+				word.WriteByte(command[i])
+			default:
+				word.WriteByte(ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inWord = true
+		case ch == '\\' && i+1 < len(command):
+			i++
+			word.WriteByte(command[i])
+			inWord = true
+		case ch == ' ' || ch == '\t':
+			flush()
+		case strings.IndexByte("|&;<>()\n", ch) >= 0:
+			// the fd of a redirection like 2>&1 isn't an argument
+			if (ch == '<' || ch == '>') && inWord && strings.Trim(word.String(), "0123456789") == "" {
+				word.Reset()
+				inWord = false
+			}
+			flush()
+			// a leading subshell or group is skipped, anything else ends the command
+			if len(words) == 0 && (ch == '(' || ch == '\n') {
+				continue
+			}
+			return words
+		default:
+			word.WriteByte(ch)
+			inWord = true
+		}
+	}
+	flush()
+
+	return words
+}
+
+// isAssignment reports whether the word is a VAR=value assignment
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	return ok && envNamePattern.MatchString(name)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+func TestShellArgs(t *testing.T) {
+	setConfig(t, &config.CRON_SHELL, "")
+
+	args, command, _ := shellArgs([]string{"echo", "hi"})
+	if !slices.Equal(args, []string{"echo", "hi"}) || command != "" {
+		t.Errorf("Expected the args unchanged without shell mode, got %q %q", args, command)
+	}
+
+	args, command, _ = shellArgs([]string{CRON_SHELL_FLAG, "echo hi | wc -c"})
+	if expected := []string{defaultShell, "-e", "-o", "pipefail", "-c", "echo hi | wc -c"}; !slices.Equal(args, expected) {
+		t.Errorf("Expected %q, got %q", expected, args)
+	}
+	if command != "echo hi | wc -c" {
+		t.Errorf("Expected the command string, got %q", command)
+	}
+
+	setConfig(t, &config.CRON_SHELL, "/bin/sh")
+	setConfig(t, &config.CRON_SHELL_OPTIONS, "-e")
+	args, _, _ = shellArgs([]string{"echo hi"})
+	if expected := []string{"/bin/sh", "-e", "-c", "echo hi"}; !slices.Equal(args, expected) {
+		t.Errorf("Expected %q, got %q", expected, args)
+	}
+
+	for _, args := range [][]string{{CRON_SHELL_FLAG}, {CRON_SHELL_FLAG, "echo", "hi"}, {" "}} {
+		if _, _, err := shellArgs(args); err == nil {
+			t.Errorf("Expected an error for %q, got nil", args)
+		}
+	}
+}
+
+func TestFirstCommand(t *testing.T) {
+	for _, tc := range []struct {
+		command string
+		words   []string
+	}{
+		{"pg_dump mydb | gzip > /backup/mydb.gz", []string{"pg_dump", "mydb"}},
+		{"PGPASSWORD=x LANG=C pg_dump mydb", []string{"pg_dump", "mydb"}},
+		{"backup.sh 2>&1 | logger", []string{"backup.sh"}},
+		{"backup.sh>out.log", []string{"backup.sh"}},
+		{`echo 'a b' "c \"d\"" e\ f; rm x`, []string{"echo", "a b", `c "d"`, "e f"}},
+		{"make -C /srv build && make -C /srv deploy", []string{"make", "-C", "/srv", "build"}},
+		{"( cd /srv && make )", []string{"cd", "/srv"}},
+		{"{ sync; }", []string{"sync"}},
+		{"\n  sync\n", []string{"sync"}},
+		{"FOO=bar", nil},
+	} {
+		if words := firstCommand(tc.command); !slices.Equal(words, tc.words) {
+			t.Errorf("Expected %q for %q, got %q", tc.words, tc.command, words)
+		}
+	}
+}
+
+func TestRunShellNamespace(t *testing.T) {
+	setConfig(t, &config.CRON_SHELL, "")
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_NAMESPACE, "")
+
+	out := filepath.Join(t.TempDir(), "out")
+	cron, err := New([]string{CRON_SHELL_FLAG, "echo hello | tr a-z A-Z > " + out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Fatalf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+	if cron.Monitor.Namespace != "echo_hello" {
+		t.Errorf("Expected namespace echo_hello, got %s", cron.Monitor.Namespace)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "HELLO\n" {
+		t.Errorf("Expected the pipeline and redirect to run, got %q", data)
+	}
+}
+
+func TestRunShellPipefail(t *testing.T) {
+	if _, err := exec.LookPath(defaultShell); err != nil {
+		t.Skip("no " + defaultShell)
+	}
+	setConfig(t, &config.CRON_SHELL, "")
+	setConfig(t, &config.CRON_METRICS, false)
+
+	// the last command of the pipeline succeeds but the first one doesn't
+	cron, _ := New([]string{CRON_SHELL_FLAG, "exit 3 | cat"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_FAIL || cron.ExitCode != 3 {
+		t.Errorf("Expected status code %d and exit code 3, got %d and %d", CRON_STATUS_FAIL, cron.StatusCode, cron.ExitCode)
+	}
+
+	// -e stops at the first failing command
+	cron, _ = New([]string{CRON_SHELL_FLAG, "false; true"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_FAIL, cron.StatusCode)
+	}
+}
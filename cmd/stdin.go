@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// CRON_STDIN values other than a path
+const (
+	CRON_STDIN_NULL    = "null"
+	CRON_STDIN_INHERIT = "inherit"
+)
+
+// checkStdin validates CRON_STDIN and CRON_STDIN_CONTENT before anything runs
+func checkStdin() error {
+	if config.CRON_STDIN_CONTENT != "" && config.CRON_STDIN != "" {
+		return errors.New("CRON_STDIN and CRON_STDIN_CONTENT can't both be set")
+	}
+
+	switch config.CRON_STDIN {
+	case "", CRON_STDIN_NULL, CRON_STDIN_INHERIT:
+		return nil
+	}
+
+	info, err := os.Stat(config.CRON_STDIN)
+	if err != nil {
+		return fmt.Errorf("invalid CRON_STDIN: %w", err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("invalid CRON_STDIN: %s is a directory", config.CRON_STDIN)
+	}
+	return nil
+}
+
+// openStdin returns what the command reads from, nil for /dev/null
+// a file is opened by the runner so the command doesn't need access to it,
+// like a shell redirection
+func openStdin() (io.Reader, error) {
+	switch {
+	case config.CRON_STDIN_CONTENT != "":
+		return strings.NewReader(config.CRON_STDIN_CONTENT), nil
+	case config.CRON_STDIN == "" || config.CRON_STDIN == CRON_STDIN_NULL:
+		return nil, nil
+	case config.CRON_STDIN == CRON_STDIN_INHERIT:
+		return os.Stdin, nil
+	}
+
+	file, err := os.Open(config.CRON_STDIN)
+	if err != nil {
+		return nil, fmt.Errorf("stdin: %w", err)
+	}
+	return file, nil
+}
+
+// dryrunStdin prints where the command reads from, the content isn't shown
+func dryrunStdin() {
+	switch {
+	case config.CRON_STDIN_CONTENT != "":
+		fmt.Printf("DRYRUN: Stdin: %d bytes of CRON_STDIN_CONTENT\n", len(config.CRON_STDIN_CONTENT))
+	case config.CRON_STDIN != "":
+		fmt.Printf("DRYRUN: Stdin: %s\n", config.CRON_STDIN)
+	}
+}
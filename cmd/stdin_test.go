@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// runCat runs cat and returns what it read
+func runCat(t *testing.T) string {
+	setConfig(t, &config.CRON_METRICS, false)
+
+	out := filepath.Join(t.TempDir(), "out")
+	cron, err := New([]string{"sh", "-c", "cat > " + out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS {
+		t.Fatalf("Expected status code %d, got %d", CRON_STATUS_SUCCESS, cron.StatusCode)
+	}
+
+	data, _ := os.ReadFile(out)
+	return string(data)
+}
+
+func TestRunStdinNull(t *testing.T) {
+	for _, stdin := range []string{"", CRON_STDIN_NULL} {
+		setConfig(t, &config.CRON_STDIN, stdin)
+		if got := runCat(t); got != "" {
+			t.Errorf("Expected nothing for %q, got %q", stdin, got)
+		}
+	}
+}
+
+func TestRunStdinFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.sql")
+	os.WriteFile(path, []byte("SELECT 1;\nSELECT 2;\n"), 0600)
+	setConfig(t, &config.CRON_STDIN, path)
+
+	if got := runCat(t); got != "SELECT 1;\nSELECT 2;\n" {
+		t.Errorf("Expected the file, got %q", got)
+	}
+}
+
+func TestRunStdinContent(t *testing.T) {
+	setConfig(t, &config.CRON_STDIN_CONTENT, "VACUUM ANALYZE;")
+
+	if got := runCat(t); got != "VACUUM ANALYZE;" {
+		t.Errorf("Expected the content, got %q", got)
+	}
+}
+
+func TestStdinInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, tc := range []struct{ stdin, content string }{
+		{filepath.Join(dir, "missing"), ""},
+		{dir, ""},
+		{CRON_STDIN_NULL, "SELECT 1;"},
+	} {
+		setConfig(t, &config.CRON_STDIN, tc.stdin)
+		setConfig(t, &config.CRON_STDIN_CONTENT, tc.content)
+		if _, err := New([]string{"cat"}); err == nil {
+			t.Errorf("Expected an error for CRON_STDIN=%q CRON_STDIN_CONTENT=%q, got nil", tc.stdin, tc.content)
+		}
+	}
+}
+
+func TestRunStdinRemoved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input")
+	os.WriteFile(path, nil, 0600)
+	setConfig(t, &config.CRON_STDIN, path)
+	setConfig(t, &config.CRON_METRICS, false)
+
+	// gone by the time it runs
+	cron, _ := New([]string{"cat"})
+	os.Remove(path)
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_FAIL {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_FAIL, cron.StatusCode)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// runAs is the user the command is started as when the runner drops privileges
+type runAs struct {
+	name   string
+	uid    uint32
+	gid    uint32
+	groups []uint32
+	home   string
+	shell  string
+}
+
+// checkRunAs validates the CRON_USER options that can be checked without looking anything up
+func checkRunAs() error {
+	if config.CRON_USER == "" && (config.CRON_GROUP != "" || len(config.CRON_GROUPS) > 0) {
+		return errors.New("CRON_GROUP and CRON_GROUPS require CRON_USER")
+	}
+	return nil
+}
+
+// lookupRunAs resolves CRON_USER, CRON_GROUP and CRON_GROUPS, nil if the
+// command runs as the runner's own user. Names and numeric ids both work.
+func lookupRunAs() (*runAs, error) {
+	if config.CRON_USER == "" {
+		return nil, nil
+	}
+
+	u, err := lookupUser(config.CRON_USER)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+	gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+
+	as := &runAs{
+		name:  u.Username,
+		uid:   uint32(uid),
+		gid:   uint32(gid),
+		home:  u.HomeDir,
+		shell: loginShell(u.Username),
+	}
+
+	if config.CRON_GROUP != "" {
+		if as.gid, err = lookupGroupID(config.CRON_GROUP); err != nil {
+			return nil, err
+		}
+	}
+
+	// the user's own groups like login does, unless they're given
+	groups := config.CRON_GROUPS
+	if len(groups) == 0 {
+		groups, _ = u.GroupIds()
+	}
+	for _, group := range groups {
+		gid, err := lookupGroupID(group)
+		if err != nil {
+			return nil, err
+		}
+		as.groups = append(as.groups, gid)
+	}
+
+	return as, nil
+}
+
+// applyRunAs resolves the user everything runs as, false if it doesn't exist
+func (c *Cron) applyRunAs() bool {
+	as, err := lookupRunAs()
+	if err != nil {
+		c.logf("ERROR: %v\n", err)
+		c.ExitCode, c.StatusCode = CRON_EXITCODE_INVALID_USER, CRON_STATUS_INVALID_USER
+		return false
+	}
+
+	if as != nil {
+		c.runAs = as
+		c.User = as.name
+	}
+	return true
+}
+
+// currentRunAs returns the runner's own user, nil if it can't be looked up
+func currentRunAs() *runAs {
+	u, err := user.Current()
+	if err != nil {
+		return nil
+	}
+
+	uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+	gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+
+	return &runAs{
+		name:  u.Username,
+		uid:   uint32(uid),
+		gid:   uint32(gid),
+		home:  u.HomeDir,
+		shell: loginShell(u.Username),
+	}
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if u, err := user.Lookup(name); err == nil {
+		return u, nil
+	}
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown user: %s", name)
+}
+
+func lookupGroupID(name string) (uint32, error) {
+	if g, err := user.LookupGroup(name); err == nil {
+		gid, _ := strconv.ParseUint(g.Gid, 10, 32)
+		return uint32(gid), nil
+	}
+	if gid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		if _, err := user.LookupGroupId(name); err == nil {
+			return uint32(gid), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown group: %s", name)
+}
+
+// loginShell returns the user's shell from /etc/passwd, os/user doesn't expose it
+func loginShell(name string) string {
+	file, err := os.Open("/etc/passwd")
+	if err != nil {
+		return "/bin/sh"
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) == 7 && fields[0] == name && fields[6] != "" {
+			return fields[6]
+		}
+	}
+
+	return "/bin/sh"
+}
+
+// credential returns the credential the command is started with
+func (as *runAs) credential() *syscall.Credential {
+	return &syscall.Credential{
+		Uid:    as.uid,
+		Gid:    as.gid,
+		Groups: as.groups,
+	}
+}
+
+// env returns the login env vars of the user, they replace the runner's
+func (as *runAs) env() []string {
+	return []string{
+		"HOME=" + as.home,
+		"USER=" + as.name,
+		"LOGNAME=" + as.name,
+		"SHELL=" + as.shell,
+	}
+}
+
+// chown hands a file the runner creates for the command over to the user, so
+// that the command can use it when it runs as another user than the runner
+func (as *runAs) chown(path string) error {
+	if as == nil {
+		return nil
+	}
+	return os.Chown(path, int(as.uid), int(as.gid))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devinodaniel/cron-go/cmd/config"
+)
+
+// nobodyWritableDir returns a directory the command can write to as nobody,
+// t.TempDir() is inside a directory only the runner can enter
+func nobodyWritableDir(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "cron-runner-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(dir, 0777)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestRunAsUser(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("dropping privileges needs root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no nobody user")
+	}
+
+	setConfig(t, &config.CRON_USER, "nobody")
+	setConfig(t, &config.CRON_CHILD_METRICS, true)
+	setConfig(t, &config.CRON_METRICS, true)
+	setConfig(t, &config.CRON_METRICS_DIR, t.TempDir())
+	setConfig(t, &config.CRON_NAMESPACE, "run_as_user")
+
+	result := filepath.Join(nobodyWritableDir(t), "result")
+
+	script := `echo "$(id -u) $(id -g) $HOME $USER $LOGNAME" > ` + result + `; echo 'runs_as_nobody 1' >> "$CRON_METRICS_OUTPUT"`
+	cron, _ := New([]string{"sh", "-c", script})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_SUCCESS || cron.User != "nobody" {
+		t.Fatalf("Expected status code %d as nobody, got %d as %q", CRON_STATUS_SUCCESS, cron.StatusCode, cron.User)
+	}
+
+	data, _ := os.ReadFile(result)
+	if expected := strings.Join([]string{nobody.Uid, nobody.Gid, nobody.HomeDir, "nobody", "nobody"}, " ") + "\n"; string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+
+	// the runner still writes its metrics as root, with the command's own
+	metrics, _ := os.ReadFile(filepath.Join(config.CRON_METRICS_DIR, "cron_run_as_user_metrics.prom"))
+	if !strings.Contains(string(metrics), `cron_job_runs_as_nobody{namespace="run_as_user"} 1`) {
+		t.Errorf("Expected the metrics the command published as nobody")
+	}
+}
+
+func TestRunAsUserEnv(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("dropping privileges needs root")
+	}
+	if _, err := user.Lookup("nobody"); err != nil {
+		t.Skip("no nobody user")
+	}
+
+	setConfig(t, &config.CRON_USER, "nobody")
+	setConfig(t, &config.CRON_METRICS, false)
+	setConfig(t, &config.CRON_ENV_ALLOW, []string{"ENV_TEST_*"})
+	t.Setenv("ENV_TEST_ALLOWED", "1")
+	t.Setenv("SSH_AUTH_SOCK", "/tmp/ssh-root/agent")
+	t.Setenv("SUDO_USER", "admin")
+	t.Setenv("PATH", "/root/bin:"+os.Getenv("PATH"))
+
+	result := filepath.Join(nobodyWritableDir(t), "result")
+
+	cron, _ := New([]string{"sh", "-c", "env > " + result})
+	cron.Run()
+
+	data, _ := os.ReadFile(result)
+	env := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	// only what a login gives the user and the vars explicitly allowed
+	for _, name := range []string{"SSH_AUTH_SOCK", "SUDO_USER"} {
+		if _, ok := getEnv(env, name); ok {
+			t.Errorf("Expected the runner's %s not to reach the command", name)
+		}
+	}
+	if value, _ := getEnv(env, "PATH"); value != loginPath {
+		t.Errorf("Expected PATH %s, got %s", loginPath, value)
+	}
+	if value, _ := getEnv(env, "ENV_TEST_ALLOWED"); value != "1" {
+		t.Errorf("Expected the allowed ENV_TEST_ALLOWED to be inherited, got %q", value)
+	}
+}
+
+func TestRunAsUnknownUser(t *testing.T) {
+	setConfig(t, &config.CRON_USER, "cron-runner-no-such-user")
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"true"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_INVALID_USER {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_INVALID_USER, cron.StatusCode)
+	}
+	if cron.ExitCode != CRON_EXITCODE_INVALID_USER {
+		t.Errorf("Expected exit code %d, got %d", CRON_EXITCODE_INVALID_USER, cron.ExitCode)
+	}
+}
+
+func TestRunAsUnknownGroup(t *testing.T) {
+	setConfig(t, &config.CRON_USER, "root")
+	setConfig(t, &config.CRON_GROUPS, []string{"cron-runner-no-such-group"})
+	setConfig(t, &config.CRON_METRICS, false)
+
+	cron, _ := New([]string{"true"})
+	cron.Run()
+
+	if cron.StatusCode != CRON_STATUS_INVALID_USER {
+		t.Errorf("Expected status code %d, got %d", CRON_STATUS_INVALID_USER, cron.StatusCode)
+	}
+}
+
+func TestRunAsGroupWithoutUser(t *testing.T) {
+	setConfig(t, &config.CRON_USER, "")
+	setConfig(t, &config.CRON_GROUP, "nogroup")
+
+	if _, err := New([]string{"true"}); err == nil {
+		t.Errorf("Expected an error for CRON_GROUP without CRON_USER, got nil")
+	}
+}